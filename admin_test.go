@@ -0,0 +1,220 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestAdmin(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	moderator, moderatorToken := addTestUser(t, anotherTestUser)
+	setTestUserRole(t, moderator.Id, ModeratorRole)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	admin, adminToken := addTestUser(t, thirdTestUser)
+	setTestUserRole(t, admin.Id, AdminRole)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	t.Run("users cannot moderate", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/users").
+			Set("authorization", userToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not allowed to perform this action"}`).
+			End()
+	})
+
+	t.Run("moderators can search users", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/users?q=test&status=active").
+			Set("authorization", moderatorToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("moderators cannot ban users", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/ban", user.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"banned": true}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not allowed to perform this action"}`).
+			End()
+	})
+
+	t.Run("moderators cannot suspend admins", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/suspend", admin.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"until": "2099-01-01"}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot perform this action on this user"}`).
+			End()
+	})
+
+	t.Run("moderators can suspend users", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/suspend", user.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"until": "2099-01-01", "reason": "spam"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"User suspended"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/notification").
+			Set("authorization", userToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/suspend", user.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"until": ""}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"User suspension lifted"}`).
+			End()
+	})
+
+	t.Run("moderators delete comments as placeholders", func(t *testing.T) {
+		comment := addTestComment(t, map[string]interface{}{
+			"resourceId": resource.Id,
+			"userId":     user.Id,
+			"text":       "spam",
+		})
+		reply := Comment{
+			UserId:     admin.Id,
+			ResourceId: resource.Id,
+			ParentId:   comment.Id,
+			Depth:      1,
+			Text:       "Please stop",
+		}
+		if err := app.Db.Insert(&reply); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/admin/comments/%v?reason=spam", comment.Id)).
+			Set("authorization", moderatorToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Content deleted"}`).
+			End()
+
+		if err := app.Db.Select(&comment); err != nil {
+			t.Fatal(err.Error())
+		}
+		if comment.DeletedAt == nil || comment.Text != "" {
+			t.Fatalf("Expected a blank placeholder; Got %+v", comment)
+		}
+		if err := app.Db.Select(&reply); err != nil {
+			t.Fatalf("Expected the reply to remain; Got %v", err)
+		}
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/admin/comments/%v", comment.Id)).
+			Set("authorization", moderatorToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"The content does not exist"}`).
+			End()
+	})
+
+	t.Run("moderators can delete any resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/admin/resources/%v?reason=spam", resource.Id)).
+			Set("authorization", moderatorToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Content deleted"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/admin/resources/%v", resource.Id)).
+			Set("authorization", moderatorToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"The content does not exist"}`).
+			End()
+	})
+
+	t.Run("admins cannot set an unknown role", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/role", user.Id)).
+			Set("authorization", adminToken).
+			Send(`{"role": "owner"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"role must be one of user, moderator, admin"}`).
+			End()
+	})
+
+	t.Run("admins can ban users", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/ban", user.Id)).
+			Set("authorization", adminToken).
+			Send(`{"banned": true, "reason": "spam"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"User banned"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Send(fmt.Sprintf(`{"email": "%v", "password": "test"}`, user.Email)).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Your account has been banned"}`).
+			End()
+	})
+
+	t.Run("admins can demote moderators", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/users/%v/role", moderator.Id)).
+			Set("authorization", adminToken).
+			Send(`{"role": "user"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"User role updated"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/users").
+			Set("authorization", moderatorToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not allowed to perform this action"}`).
+			End()
+	})
+
+	t.Run("admins can list recorded actions", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/admin/actions?targetId=%v", user.Id)).
+			Set("authorization", adminToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+}
@@ -3,8 +3,16 @@ package main
 import (
 	"WeKnow_api/handler"
 	"WeKnow_api/middleware"
+	. "WeKnow_api/model"
+	"WeKnow_api/services"
 	"WeKnow_api/utilities"
+	"context"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/gorilla/mux"
@@ -12,11 +20,21 @@ import (
 
 // CreateApp create a new instance of the app
 func CreateApp(config map[string]string) App {
+	mailer, err := services.NewMailerFromEnv()
+	if err != nil {
+		panic(err)
+	}
 	router := mux.NewRouter()
 	db := utilities.Connect(config)
+	views := services.NewViewCounterFromEnv(db)
+	views.Start()
 	app := App{
 		router,
 		db,
+		views,
+		services.NewHubFromEnv(),
+		mailer,
+		services.NewAuditRecorder(db),
 	}
 	app.declareRoutes()
 	return app
@@ -26,20 +44,49 @@ func CreateApp(config map[string]string) App {
 type App struct {
 	Router *mux.Router
 	Db     *pg.DB
+	Views  *services.ViewCounter
+	Hub    *services.Hub
+	Mailer services.Mailer
+	Audit  *services.AuditRecorder
 }
 
 // run start application
 func (app App) run(address string) {
 	// listen at address and handle with handler app.Router
-	err := http.ListenAndServe(address, app.Router)
-	if err != nil {
-		panic(err)
+	server := &http.Server{Addr: address, Handler: app.Router}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	// wait for a termination signal then shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	// close event streams so their connections do not hold up shutdown
+	app.Hub.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Could not shut down server gracefully: %v", err)
+	}
+	if err := app.Views.Stop(); err != nil {
+		log.Printf("Could not flush resource views: %v", err)
 	}
 }
 
 // declareRoutes declare application endpoints
 func (app App) declareRoutes() {
-	hr := &handler.Handler{Db: app.Db}
+	hr := &handler.Handler{
+		Db:     app.Db,
+		Views:  app.Views,
+		Hub:    app.Hub,
+		Mailer: app.Mailer,
+		Audit:  app.Audit,
+	}
 	mwr := &middleware.Middleware{Db: app.Db}
 
 	// Routes consist of a path and a handler function.
@@ -64,6 +111,24 @@ func (app App) declareRoutes() {
 	authSubRouter.
 		HandleFunc("/signin", hr.UserSignInEndPoint).
 		Methods("POST")
+	authSubRouter.
+		HandleFunc("/refresh", hr.RefreshToken).
+		Methods("POST")
+	authSubRouter.
+		HandleFunc("/logout", hr.Logout).
+		Methods("POST")
+	authSubRouter.
+		HandleFunc("/password/forgot", hr.ForgotPassword).
+		Methods("POST")
+	authSubRouter.
+		HandleFunc("/password/reset", hr.ResetForgottenPassword).
+		Methods("POST")
+	authSubRouter.
+		HandleFunc("/email/verify", hr.VerifyEmail).
+		Methods("GET")
+	authSubRouter.
+		HandleFunc("/email/resend", hr.ResendVerificationEmail).
+		Methods("POST")
 
 	pr := r.NewRoute().Subrouter()
 	// Middleware Protect data endpoints
@@ -80,30 +145,186 @@ func (app App) declareRoutes() {
 	connectionSubRouter.
 		HandleFunc("/followers", hr.GetAllFollowers).
 		Methods("GET")
+	connectionSubRouter.
+		HandleFunc("/{userId:[0-9]+}", hr.Unfollow).
+		Methods("DELETE")
+	connectionSubRouter.
+		HandleFunc("/requests/incoming", hr.GetIncomingFollowRequests).
+		Methods("GET")
+	connectionSubRouter.
+		HandleFunc("/requests/outgoing", hr.GetOutgoingFollowRequests).
+		Methods("GET")
+	connectionSubRouter.
+		HandleFunc("/requests/{userId:[0-9]+}", hr.RespondToFollowRequest).
+		Methods("PUT")
+	connectionSubRouter.
+		HandleFunc("/block", hr.BlockUser).
+		Methods("POST")
+	connectionSubRouter.
+		HandleFunc("/block", hr.GetBlockedUsers).
+		Methods("GET")
+	connectionSubRouter.
+		HandleFunc("/block/{userId:[0-9]+}", hr.UnblockUser).
+		Methods("DELETE")
+	connectionSubRouter.
+		HandleFunc("/mute", hr.MuteUser).
+		Methods("POST")
+	connectionSubRouter.
+		HandleFunc("/mute", hr.GetMutedUsers).
+		Methods("GET")
+	connectionSubRouter.
+		HandleFunc("/mute/{userId:[0-9]+}", hr.UnmuteUser).
+		Methods("DELETE")
+
+	// Handle real-time event stream requests
+	pr.HandleFunc("/api/v1/stream", hr.Stream).Methods("GET")
+
+	// Handle feed requests
+	pr.HandleFunc("/api/v1/feed", hr.GetFeed).Methods("GET")
+
+	// Handle search requests
+	pr.HandleFunc("/api/v1/search", hr.Search).Methods("GET")
+
+	// Handle message requests
+	messageSubRouter := pr.PathPrefix("/api/v1/message").Subrouter()
+	messageSubRouter.
+		HandleFunc("", hr.SendMessage).
+		Methods("POST")
+	messageSubRouter.
+		HandleFunc("/conversations", hr.GetConversations).
+		Methods("GET")
+	messageSubRouter.
+		HandleFunc("/unread", hr.GetUnreadMessagesCount).
+		Methods("GET")
+	messageSubRouter.
+		HandleFunc("/read", hr.MarkMessagesRead).
+		Methods("PUT")
+	messageSubRouter.
+		HandleFunc("/{userId:[0-9]+}", hr.GetMessages).
+		Methods("GET")
+
+	// Handle notification requests
+	notificationSubRouter := pr.PathPrefix("/api/v1/notification").Subrouter()
+	notificationSubRouter.
+		HandleFunc("", hr.GetNotifications).
+		Methods("GET")
+	notificationSubRouter.
+		HandleFunc("", hr.MarkAllNotificationsRead).
+		Methods("PUT")
+	notificationSubRouter.
+		HandleFunc("/settings", hr.GetNotificationSettings).
+		Methods("GET")
+	notificationSubRouter.
+		HandleFunc("/settings", hr.UpdateNotificationSettings).
+		Methods("PUT")
+	notificationSubRouter.
+		HandleFunc("/{notificationId:[0-9]+}", hr.MarkNotificationRead).
+		Methods("PUT")
 
 	// Handle collection requests
 	collectionSubRouter := pr.PathPrefix("/api/v1/collection").Subrouter()
 	collectionSubRouter.
-		HandleFunc("", hr.CreateCollectionEndPoint).
+		HandleFunc("", hr.GetAllCollections).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/add/{collectionId:[0-9]+}", hr.AddResourceToCollection).
 		Methods("POST")
 	collectionSubRouter.
-		HandleFunc("", hr.GetAllCollections).
+		HandleFunc("/{collectionId:[0-9]+}", hr.GetCollection).
 		Methods("GET")
 	collectionSubRouter.
-		HandleFunc("/{collectionID}", hr.UpdateCollectionEndPoint).
+		HandleFunc("/{collectionId:[0-9]+}", hr.DeleteCollection).
+		Methods("DELETE")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/resource/{resourceId:[0-9]+}", hr.MoveResourceInCollection).
 		Methods("PUT")
 	collectionSubRouter.
-		HandleFunc("/add/{collectionId:[0-9]+}", hr.AddResourceToCollection).
+		HandleFunc("/{collectionId:[0-9]+}/resource/{resourceId:[0-9]+}", hr.RemoveResourceFromCollection).
+		Methods("DELETE")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/collaborators", hr.GetCollaborators).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/collaborators", hr.InviteCollaborator).
+		Methods("POST")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/collaborators/{userId:[0-9]+}", hr.UpdateCollaborator).
+		Methods("PUT")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/collaborators/{userId:[0-9]+}", hr.RemoveCollaborator).
+		Methods("DELETE")
+	collectionSubRouter.
+		HandleFunc("/invitations", hr.GetInvitations).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/invitations/{collectionId:[0-9]+}", hr.RespondToInvitation).
+		Methods("PUT")
+	collectionSubRouter.
+		HandleFunc("/public", hr.GetPublicCollections).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/shared/{shareToken}", hr.GetSharedCollection).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/following", hr.GetFollowedCollections).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/follow", hr.FollowCollection).
+		Methods("POST")
+	collectionSubRouter.
+		HandleFunc("/follow/{collectionId:[0-9]+}", hr.UnfollowCollection).
+		Methods("DELETE")
+	collectionSubRouter.
+		HandleFunc("/fork", hr.ForkCollection).
+		Methods("POST")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/resource/{resourceId:[0-9]+}/section", hr.UpdateSection).
+		Methods("PUT")
+	collectionSubRouter.
+		HandleFunc("/enrollments", hr.GetEnrollments).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/enroll", hr.EnrollInLearningPath).
 		Methods("POST")
+	collectionSubRouter.
+		HandleFunc("/enroll/{collectionId:[0-9]+}", hr.LeaveLearningPath).
+		Methods("DELETE")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/progress", hr.GetLearningProgress).
+		Methods("GET")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/progress/{resourceId:[0-9]+}", hr.CompleteStep).
+		Methods("PUT")
+	collectionSubRouter.
+		HandleFunc("/{collectionId:[0-9]+}/stats", hr.GetLearningStats).
+		Methods("GET")
+
+	collectionTagsSubRouter := collectionSubRouter.NewRoute().Subrouter()
+	// Middleware For added tags; select if exists else create and select
+	collectionTagsSubRouter.Use(mwr.CreateAndSelectAddedTags)
+	// Middleware select removed tags
+	collectionTagsSubRouter.Use(mwr.SelectRemovedTags)
+	collectionTagsSubRouter.
+		HandleFunc("", hr.CreateCollectionEndPoint).
+		Methods("POST")
+	collectionTagsSubRouter.
+		HandleFunc("/{collectionID}", hr.UpdateCollectionEndPoint).
+		Methods("PUT")
 
 	userSubRouter := pr.PathPrefix("/api/v1/user").Subrouter()
 	userSubRouter.
 		HandleFunc("/profile", hr.UpdateProfile).Methods("PUT")
 	userSubRouter.
 		HandleFunc("/password/reset", hr.ResetPassword).Methods("PUT")
+	userSubRouter.
+		HandleFunc("/{userId:[0-9]+}/recommendations", hr.GetRecommendedResources).
+		Methods("GET")
 
 	// Handle resource requests
 	resourceSubRouter := pr.PathPrefix("/api/v1/resource").Subrouter()
+	resourceSubRouter.
+		HandleFunc("", hr.GetResources).
+		Methods("GET")
 	resourceSubRouter.
 		HandleFunc("/{resourceId:[0-9]+}", hr.GetResource).
 		Methods("GET")
@@ -111,10 +332,28 @@ func (app App) declareRoutes() {
 		HandleFunc("/{resourceId:[0-9]+}", hr.DeleteResource).
 		Methods("DELETE")
 	resourceSubRouter.
-		HandleFunc("/recommend/{resourceId:[0-9]+}", hr.RecommendResource).
+		HandleFunc("/{resourceId:[0-9]+}/recommenders", hr.GetRecommenders).
 		Methods("GET")
+	resourceSubRouter.
+		HandleFunc("/{resourceId:[0-9]+}/ratings", hr.GetRatings).
+		Methods("GET")
+	resourceSubRouter.
+		HandleFunc("/{resourceId:[0-9]+}/rating", hr.RateResource).
+		Methods("PUT")
+	resourceSubRouter.
+		HandleFunc("/{resourceId:[0-9]+}/rating", hr.RemoveRating).
+		Methods("DELETE")
+	resourceSubRouter.
+		HandleFunc("/recommend/{resourceId:[0-9]+}", hr.RecommendResource).
+		Methods("POST").
+		Name(middleware.RecommendResourceRoute)
+	resourceSubRouter.
+		HandleFunc("/recommend/{resourceId:[0-9]+}", hr.WithdrawRecommendation).
+		Methods("DELETE")
 
 	resourceTagsSubRouter := resourceSubRouter.NewRoute().Subrouter()
+	// Middleware Only users with a verified email can post resources
+	resourceTagsSubRouter.Use(mwr.RequireVerifiedEmail)
 	// Middleware For added tags; select if exists else create and select
 	resourceTagsSubRouter.Use(mwr.CreateAndSelectAddedTags)
 	resourceTagsSubRouter.
@@ -134,4 +373,70 @@ func (app App) declareRoutes() {
 	commentSubRouter.
 		HandleFunc("", hr.GetComments).
 		Methods("GET")
+	commentSubRouter.
+		HandleFunc("/{commentId:[0-9]+}", hr.UpdateComment).
+		Methods("PUT")
+	commentSubRouter.
+		HandleFunc("/{commentId:[0-9]+}", hr.DeleteComment).
+		Methods("DELETE")
+	commentSubRouter.
+		HandleFunc("/{commentId:[0-9]+}/edits", hr.GetCommentEdits).
+		Methods("GET")
+	commentSubRouter.
+		HandleFunc("/{commentId:[0-9]+}/reactions", hr.ReactToComment).
+		Methods("POST")
+	commentSubRouter.
+		HandleFunc("/{commentId:[0-9]+}/reactions/{reaction}", hr.RemoveCommentReaction).
+		Methods("DELETE")
+
+	// Handle report requests
+	reportSubRouter := pr.PathPrefix("/api/v1/report").Subrouter()
+	reportSubRouter.
+		HandleFunc("", hr.ReportContent).
+		Methods("POST")
+	reportSubRouter.
+		HandleFunc("", hr.GetMyReports).
+		Methods("GET")
+
+	// Handle moderator and admin requests
+	moderatorSubRouter := pr.PathPrefix("/api/v1/admin").Subrouter()
+	// Middleware Only moderators and admins can moderate
+	moderatorSubRouter.Use(mwr.RequireRole(ModeratorRole, AdminRole))
+	moderatorSubRouter.
+		HandleFunc("/users", hr.GetUsers).
+		Methods("GET")
+	moderatorSubRouter.
+		HandleFunc("/users/{userId:[0-9]+}/suspend", hr.SuspendUser).
+		Methods("PUT")
+	moderatorSubRouter.
+		HandleFunc("/resources/{resourceId:[0-9]+}", hr.ForceDeleteResource).
+		Methods("DELETE")
+	moderatorSubRouter.
+		HandleFunc("/comments/{commentId:[0-9]+}", hr.ForceDeleteComment).
+		Methods("DELETE")
+	moderatorSubRouter.
+		HandleFunc("/reports", hr.GetReports).
+		Methods("GET")
+	moderatorSubRouter.
+		HandleFunc("/reports/{reportId:[0-9]+}/claim", hr.ClaimReport).
+		Methods("PUT")
+	moderatorSubRouter.
+		HandleFunc("/reports/{reportId:[0-9]+}/resolve", hr.ResolveReport).
+		Methods("PUT")
+
+	adminSubRouter := moderatorSubRouter.NewRoute().Subrouter()
+	// Middleware Only admins can ban users and manage roles
+	adminSubRouter.Use(mwr.RequireRole(AdminRole))
+	adminSubRouter.
+		HandleFunc("/users/{userId:[0-9]+}/ban", hr.BanUser).
+		Methods("PUT")
+	adminSubRouter.
+		HandleFunc("/users/{userId:[0-9]+}/role", hr.UpdateUserRole).
+		Methods("PUT")
+	adminSubRouter.
+		HandleFunc("/actions", hr.GetAdminActions).
+		Methods("GET")
+	adminSubRouter.
+		HandleFunc("/audit", hr.GetAuditEvents).
+		Methods("GET")
 }
@@ -0,0 +1,151 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+// findAuditEvent get the latest audit event of an action on a target
+func findAuditEvent(t *testing.T, action string, targetId int64) AuditEvent {
+	var event AuditEvent
+	err := app.Db.Model(&event).
+		Where("action = ? AND target_id = ?", action, targetId).
+		Order("id DESC").
+		Limit(1).
+		Select()
+	if err != nil {
+		t.Fatalf("Expected a %v audit event; Got %v", action, err)
+	}
+	return event
+}
+
+func TestAuditLog(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	admin, adminToken := addTestUser(t, anotherTestUser)
+	setTestUserRole(t, admin.Id, AdminRole)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	t.Run("records failed and successful sign ins", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Set("User-Agent", "audit-test").
+			Send(fmt.Sprintf(`{"email": "%v", "password": "wrong"}`, user.Email)).
+			Expect(401).
+			End()
+
+		event := findAuditEvent(t, "signInFailed", user.Id)
+		if event.ActorId != 0 || event.Details["reason"] != "wrongPassword" {
+			t.Fatalf("Expected an anonymous wrongPassword failure; Got %+v", event)
+		}
+		if event.Ip == "" || event.UserAgent != "audit-test" {
+			t.Fatalf("Expected the address and user agent of the request; Got %+v", event)
+		}
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Send(fmt.Sprintf(`{"email": "%v", "password": "test"}`, user.Email)).
+			Expect(200).
+			End()
+
+		if event := findAuditEvent(t, "signIn", user.Id); event.ActorId != user.Id {
+			t.Fatalf("Expected the user as actor of the sign in; Got %+v", event)
+		}
+	})
+
+	t.Run("records profile changes as a diff", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/user/profile").
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"username": "renamed", "phoneNumber": "%v"}`, user.PhoneNumber)).
+			Expect(200).
+			End()
+
+		event := findAuditEvent(t, "updateProfile", user.Id)
+		if event.Before["username"] != user.Username || event.After["username"] != "renamed" {
+			t.Fatalf("Expected the username change; Got %+v", event)
+		}
+		if _, ok := event.After["phoneNumber"]; ok {
+			t.Fatalf("Expected unchanged fields to be left out; Got %+v", event)
+		}
+	})
+
+	t.Run("records resource updates and deletes", func(t *testing.T) {
+		resourceURI := fmt.Sprintf("/api/v1/resource/%v", resource.Id)
+		Request(testServer.URL, t).
+			Put(resourceURI).
+			Set("authorization", userToken).
+			Send(`{"title": "An audited resource"}`).
+			Expect(200).
+			End()
+
+		event := findAuditEvent(t, "updateResource", resource.Id)
+		if event.Before["title"] != resource.Title || event.After["title"] != "An audited resource" {
+			t.Fatalf("Expected the title change; Got %+v", event)
+		}
+
+		Request(testServer.URL, t).
+			Delete(resourceURI).
+			Set("authorization", userToken).
+			Expect(200).
+			End()
+
+		event = findAuditEvent(t, "deleteResource", resource.Id)
+		if event.ActorId != user.Id || event.Before["link"] != resource.Link {
+			t.Fatalf("Expected the deleted resource; Got %+v", event)
+		}
+	})
+
+	t.Run("cannot change or delete audit events", func(t *testing.T) {
+		if _, err := app.Db.Exec("UPDATE audit_events SET action = 'changed'"); err == nil {
+			t.Fatal("Expected audit events to be append-only")
+		}
+		if _, err := app.Db.Exec("DELETE FROM audit_events"); err == nil {
+			t.Fatal("Expected audit events to be append-only")
+		}
+	})
+
+	t.Run("users cannot read the audit log", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/audit").
+			Set("authorization", userToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not allowed to perform this action"}`).
+			End()
+	})
+
+	t.Run("admins can filter the audit log", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf(
+				"/api/v1/admin/audit?actorId=%v&targetType=resource&from=2000-01-01",
+				user.Id,
+			)).
+			Set("authorization", adminToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/audit?actorId=me").
+			Set("authorization", adminToken).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"actorId must be a number"}`).
+			End()
+	})
+}
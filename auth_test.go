@@ -0,0 +1,363 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestRefreshTokensAndLogout(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	t.Run("cannot refresh without a refresh token", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(`{"refreshToken": ""}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"A valid refreshToken is required"}`).
+			End()
+	})
+
+	t.Run("cannot refresh with an unknown refresh token", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(`{"refreshToken": "unknown"}`).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Invalid refresh token"}`).
+			End()
+	})
+
+	t.Run("cannot refresh with an expired refresh token", func(t *testing.T) {
+		refreshToken := addTestRefreshToken(t, user.Id, time.Now().Add(-time.Hour))
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, refreshToken)).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Refresh token has expired"}`).
+			End()
+	})
+
+	t.Run("rotates a refresh token once", func(t *testing.T) {
+		refreshToken := addTestRefreshToken(t, user.Id, time.Now().Add(time.Hour))
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, refreshToken)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, refreshToken)).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Refresh token has been revoked"}`).
+			End()
+	})
+
+	t.Run("can log out of a session", func(t *testing.T) {
+		refreshToken := addTestRefreshToken(t, user.Id, time.Now().Add(time.Hour))
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/logout").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, refreshToken)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Logged out successfully"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, refreshToken)).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Refresh token has been revoked"}`).
+			End()
+	})
+
+	t.Run("can log out of all devices", func(t *testing.T) {
+		refreshToken := addTestRefreshToken(t, user.Id, time.Now().Add(time.Hour))
+		otherRefreshToken := addTestRefreshToken(t, user.Id, time.Now().Add(time.Hour))
+		// tokens issued in the same second as the logout stay valid
+		time.Sleep(time.Second)
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/logout").
+			Send(fmt.Sprintf(`{"refreshToken": "%v", "allDevices": true}`, refreshToken)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Logged out successfully"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/refresh").
+			Send(fmt.Sprintf(`{"refreshToken": "%v"}`, otherRefreshToken)).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Refresh token has been revoked"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/notification").
+			Set("authorization", userToken).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Token has been revoked"}`).
+			End()
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	customizeEnvVariables(t, map[string]string{
+		"PASSWORD_RESET_URL": "http://localhost/reset-password",
+	})
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	_, userToken := addTestUser(t, testUser)
+	email := testUser["email"].(string)
+	var resetToken string
+
+	t.Run("requires a valid email", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/forgot").
+			Send(`{"email": "not an email"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Please enter a valid email"}`).
+			End()
+	})
+
+	t.Run("does not reveal unregistered emails", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/forgot").
+			Send(`{"email": "unknown@gmail.com"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"If the email is registered, a password reset link has been sent"}`).
+			End()
+	})
+
+	t.Run("emails a reset link to registered emails", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/forgot").
+			Send(fmt.Sprintf(`{"email": "%v"}`, email)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"If the email is registered, a password reset link has been sent"}`).
+			End()
+
+		mails := waitForMail(t, email, 1)
+		match := regexp.MustCompile(`reset-password\?token=([\w-]+)`).
+			FindStringSubmatch(mails[len(mails)-1].Body)
+		if match == nil {
+			t.Fatal("Expected a reset link in the mail")
+		}
+		resetToken = match[1]
+	})
+
+	t.Run("cannot reset with an invalid token", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/reset").
+			Send(`{"token": "invalid", "password": "new password"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Invalid or expired reset token"}`).
+			End()
+	})
+
+	t.Run("can reset the password once", func(t *testing.T) {
+		// tokens issued in the same second as the reset stay valid
+		time.Sleep(time.Second)
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/reset").
+			Send(fmt.Sprintf(`{"token": "%v", "password": "new password"}`, resetToken)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Password reset successfully"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/password/reset").
+			Send(fmt.Sprintf(`{"token": "%v", "password": "other password"}`, resetToken)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Invalid or expired reset token"}`).
+			End()
+	})
+
+	t.Run("ends existing sessions", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/notification").
+			Set("authorization", userToken).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Token has been revoked"}`).
+			End()
+	})
+
+	t.Run("can sign in with the new password", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Send(fmt.Sprintf(`{"email": "%v", "password": "new password"}`, email)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+}
+
+func TestEmailVerification(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	customizeEnvVariables(t, map[string]string{
+		"EMAIL_VERIFICATION_URL": "http://localhost/verify-email",
+	})
+	verificationLink := regexp.MustCompile(`verify-email\?token=([\w.-]+)`)
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	t.Run("sends a verification link on sign-up", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signup").
+			Send(`{"username": "newUser", "email": "newUser@gmail.com",
+			"password": "password", "phoneNumber": "08123425699"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		mails := waitForMail(t, "newUser@gmail.com", 1)
+		match := verificationLink.FindStringSubmatch(mails[0].Body)
+		if match == nil {
+			t.Fatal("Expected a verification link in the mail")
+		}
+
+		Request(testServer.URL, t).
+			Get("/api/v1/auth/email/verify?token="+match[1]).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Email verified successfully"}`).
+			End()
+	})
+
+	t.Run("cannot verify with an invalid link", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/auth/email/verify?token=invalid").
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Invalid or expired verification link"}`).
+			End()
+	})
+
+	t.Run("cannot use a verification token as an authorization token", func(t *testing.T) {
+		token, err := user.GenerateVerificationToken(user.Email)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		Request(testServer.URL, t).
+			Get("/api/v1/collection").
+			Set("authorization", "Bearer "+token).
+			Expect(401).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("does not resend links to verified emails", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/email/resend").
+			Send(fmt.Sprintf(`{"email": "%v"}`, user.Email)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"If the email is awaiting verification, a verification link has been sent"}`).
+			End()
+	})
+
+	t.Run("keeps the email until a new email is verified", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/user/profile").
+			Set("authorization", userToken).
+			Send(`{"email": "changed@gmail.com"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Profile Updated successfully","updatedProfile":{"pendingEmail":"changed@gmail.com"}}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Send(fmt.Sprintf(`{"email": "%v", "password": "test"}`, user.Email)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		mails := waitForMail(t, "changed@gmail.com", 1)
+		match := verificationLink.FindStringSubmatch(mails[0].Body)
+		if match == nil {
+			t.Fatal("Expected a verification link in the mail")
+		}
+
+		Request(testServer.URL, t).
+			Get("/api/v1/auth/email/verify?token="+match[1]).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Email verified successfully"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/auth/signin").
+			Send(`{"email": "changed@gmail.com", "password": "test"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("cannot change to an email in use", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/user/profile").
+			Set("authorization", userToken).
+			Send(`{"email": "newUser@gmail.com"}`).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Email is already in use"}`).
+			End()
+	})
+
+	t.Run("unverified users cannot post resources", func(t *testing.T) {
+		_, err := app.Db.Model(&User{}).
+			Set("verified_at = NULL").
+			Where("id = ?", user.Id).
+			Update()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		Request(testServer.URL, t).
+			Post("/api/v1/resource").
+			Set("authorization", userToken).
+			Send(`{"title": "A resource", "link": "http://example.com", "type": "article"}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Please verify your email address first"}`).
+			End()
+	})
+}
@@ -0,0 +1,237 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+)
+
+func TestCollectionCollaborators(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	editor, editorToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	viewer, viewerToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = editor.Id
+	resource := addTestResource(t, testResource)
+
+	privateResource := dummyData["privateResource"].(map[string]interface{})
+	privateResource["userId"] = owner.Id
+	hidden := addTestResource(t, privateResource)
+
+	testCollection := dummyData["collection1"].(map[string]interface{})
+	testCollection["userId"] = owner.Id
+	collection := addTestCollection(t, testCollection)
+
+	collectionURI := fmt.Sprintf("/api/v1/collection/%v", collection.Id)
+	collaboratorsURI := collectionURI + "/collaborators"
+	addURI := fmt.Sprintf("/api/v1/collection/add/%v", collection.Id)
+	addPayload := fmt.Sprintf(`{"ResourceId": %v}`, resource.Id)
+
+	t.Run("cannot add to the collection of another user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(addURI).
+			Set("authorization", editorToken).
+			Send(addPayload).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+	})
+
+	t.Run("only the owner can invite collaborators", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(collaboratorsURI).
+			Set("authorization", editorToken).
+			Send(fmt.Sprintf(`{"userId": %v, "role": "editor"}`, editor.Id)).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post(collaboratorsURI).
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(`{"userId": %v, "role": "admin"}`, editor.Id)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"role must be one of viewer, editor"}`).
+			End()
+	})
+
+	t.Run("can invite collaborators", func(t *testing.T) {
+		for user, role := range map[int64]string{editor.Id: "editor", viewer.Id: "viewer"} {
+			Request(testServer.URL, t).
+				Post(collaboratorsURI).
+				Set("authorization", ownerToken).
+				Send(fmt.Sprintf(`{"userId": %v, "role": "%v"}`, user, role)).
+				Expect(201).
+				Expect("Content-Type", "application/json").
+				Expect(`{"message":"Invitation sent"}`).
+				End()
+		}
+
+		Request(testServer.URL, t).
+			Post(collaboratorsURI).
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(`{"userId": %v, "role": "viewer"}`, editor.Id)).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"User is already a collaborator or invited"}`).
+			End()
+	})
+
+	t.Run("invited users cannot edit until they accept", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(addURI).
+			Set("authorization", editorToken).
+			Send(addPayload).
+			Expect(403).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/invitations").
+			Set("authorization", editorToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		for _, token := range []string{editorToken, viewerToken} {
+			Request(testServer.URL, t).
+				Put(fmt.Sprintf("/api/v1/collection/invitations/%v", collection.Id)).
+				Set("authorization", token).
+				Send(`{"accept": true}`).
+				Expect(200).
+				Expect("Content-Type", "application/json").
+				Expect(`{"message":"Invitation accepted"}`).
+				End()
+		}
+	})
+
+	t.Run("editors can add resources they can access", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(addURI).
+			Set("authorization", editorToken).
+			Send(addPayload).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"resource added to collection"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post(addURI).
+			Set("authorization", editorToken).
+			Send(fmt.Sprintf(`{"ResourceId": %v}`, hidden.Id)).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Resource or Collection does not exist"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", editorToken).
+			Send(`{"name": "study group"}`).
+			Expect(200).
+			End()
+	})
+
+	t.Run("viewers can see but not edit the collection", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", viewerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", viewerToken).
+			Send(`{"name": "renamed by a viewer"}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("%v/resource/%v", collectionURI, resource.Id)).
+			Set("authorization", viewerToken).
+			Expect(403).
+			End()
+	})
+
+	t.Run("editors cannot delete the collection", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(collectionURI).
+			Set("authorization", editorToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+	})
+
+	t.Run("shared collections are listed for collaborators", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/collection").
+			Set("authorization", viewerToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{1}).
+			End()
+	})
+
+	t.Run("the owner can change roles and remove collaborators", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("%v/%v", collaboratorsURI, viewer.Id)).
+			Set("authorization", ownerToken).
+			Send(`{"role": "editor"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collaborator role updated"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("%v/%v", collaboratorsURI, viewer.Id)).
+			Set("authorization", ownerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collaborator removed"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", viewerToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Collection not found"}`).
+			End()
+	})
+
+	t.Run("collaborators can leave a collection", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("%v/%v", collaboratorsURI, editor.Id)).
+			Set("authorization", editorToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collaborator removed"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("%v/%v", collaboratorsURI, editor.Id)).
+			Set("authorization", editorToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"User is not a collaborator of this collection"}`).
+			End()
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
 )
 
 var valueMap interface{}
@@ -186,9 +187,9 @@ func TestUpdateCollection(t *testing.T) {
 			Put("api/v1/collection/2").
 			Set("authorization", userToken).
 			Send(`{"name": "new name"}`).
-			Expect(404).
+			Expect(403).
 			Expect("Content-Type", "application/json").
-			Expect(`{"error":"Collection not found"}`).
+			Expect(`{"error":"You cannot edit this collection"}`).
 			End()
 	})
 
@@ -445,3 +446,342 @@ func TestResource(t *testing.T) {
 	})
 
 }
+
+func TestManageCollection(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	var resources []Resource
+	for _, key := range []string{"testResource", "privateResource", "followersResource"} {
+		testResource := dummyData[key].(map[string]interface{})
+		testResource["userId"] = user.Id
+		resources = append(resources, addTestResource(t, testResource))
+	}
+
+	testCollection := dummyData["collection1"].(map[string]interface{})
+	testCollection["userId"] = user.Id
+	collection := addTestCollection(t, testCollection)
+	collectionURI := fmt.Sprintf("/api/v1/collection/%v", collection.Id)
+
+	for _, resource := range resources {
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/collection/add/%v", collection.Id)).
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"ResourceId": %v}`, resource.Id)).
+			Expect(200).
+			End()
+	}
+	// a resource that became private to another user after it was added
+	otherResource := Resource{
+		Title:   "Another private resource",
+		Type:    "video",
+		Link:    "https://localhost.textual/material/9.pdf",
+		Privacy: "private",
+		UserId:  anotherUser.Id,
+	}
+	if err := app.Db.Insert(&otherResource); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := app.Db.Insert(&ResourceCollection{
+		ResourceId:   otherResource.Id,
+		CollectionId: collection.Id,
+		Position:     4,
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// getResourceIds get the ids of the resources of the collection in order
+	getResourceIds := func(t *testing.T) []int64 {
+		request, _ := http.NewRequest("GET", testServer.URL+collectionURI, nil)
+		request.Header.Set("authorization", userToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct{ Collection Collection }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		var ids []int64
+		for _, resource := range payload.Collection.Resources {
+			ids = append(ids, resource.Id)
+		}
+		return ids
+	}
+
+	// expectResourceIds check the order of the resources of the collection
+	expectResourceIds := func(t *testing.T, expected ...int64) {
+		if obtained := getResourceIds(t); fmt.Sprint(obtained) != fmt.Sprint(expected) {
+			t.Fatalf("Expected resources %v; Got %v", expected, obtained)
+		}
+	}
+
+	t.Run("can get a collection with the resources they can access", func(t *testing.T) {
+		expectResourceIds(t, resources[0].Id, resources[1].Id, resources[2].Id)
+	})
+
+	t.Run("cannot get the collection of another user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Collection not found"}`).
+			End()
+	})
+
+	t.Run("can move a resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("%v/resource/%v", collectionURI, resources[2].Id)).
+			Set("authorization", userToken).
+			Send(`{"position": 1}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Resource moved in collection"}`).
+			End()
+		expectResourceIds(t, resources[2].Id, resources[0].Id, resources[1].Id)
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("%v/resource/%v", collectionURI, resources[0].Id)).
+			Set("authorization", userToken).
+			Send(`{"position": 100}`).
+			Expect(200).
+			End()
+		expectResourceIds(t, resources[2].Id, resources[1].Id, resources[0].Id)
+	})
+
+	t.Run("cannot move a resource to an invalid position", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("%v/resource/%v", collectionURI, resources[0].Id)).
+			Set("authorization", userToken).
+			Send(`{"position": 0}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"position must be a whole number from 1"}`).
+			End()
+	})
+
+	t.Run("can remove a resource", func(t *testing.T) {
+		resourceURI := fmt.Sprintf("%v/resource/%v", collectionURI, resources[1].Id)
+		Request(testServer.URL, t).
+			Delete(resourceURI).
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Resource removed from collection"}`).
+			End()
+		expectResourceIds(t, resources[2].Id, resources[0].Id)
+
+		Request(testServer.URL, t).
+			Delete(resourceURI).
+			Set("authorization", userToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Resource is not in this collection"}`).
+			End()
+	})
+
+	t.Run("closes the gap of a deleted resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/resource/%v", otherResource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			End()
+
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/collection/add/%v", collection.Id)).
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"ResourceId": %v}`, resources[1].Id)).
+			Expect(200).
+			End()
+		expectResourceIds(t, resources[2].Id, resources[0].Id, resources[1].Id)
+
+		var positions []int
+		err := app.Db.Model(&ResourceCollection{}).
+			Column("position").
+			Where("collection_id = ?", collection.Id).
+			Order("position ASC").
+			Select(&positions)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if fmt.Sprint(positions) != "[1 2 3]" {
+			t.Fatalf("Expected positions [1 2 3]; Got %v", positions)
+		}
+	})
+
+	t.Run("cannot delete the collection of another user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(collectionURI).
+			Set("authorization", anotherUserToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+	})
+
+	t.Run("can delete a collection and keep its resources", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(collectionURI).
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collection deleted"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", userToken).
+			Expect(404).
+			End()
+
+		count, err := app.Db.Model(&Resource{}).Count()
+		if err != nil || count != 3 {
+			t.Fatalf("Expected the 3 resources to remain; Got %v %v", count, err)
+		}
+	})
+}
+
+func TestCollectionTags(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedTag struct{ Title string }
+	type ExpectedCollection struct {
+		Name string
+		Tags []ExpectedTag
+	}
+	type ExpectedResponse struct {
+		TotalCount  int
+		Collections []struct{ Name string }
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	_, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	_, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	t.Run("can create a collection with tags", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/collection").
+			Set("authorization", userToken).
+			Send(`{"name": "reading list", "tags": ["golang", " databases "]}`).
+			Expect(201).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"reading list collection was created successfully"}`).
+			End()
+	})
+
+	var collection Collection
+	if err := app.Db.Model(&collection).Where("name = ?", "reading list").Select(); err != nil {
+		t.Fatal(err.Error())
+	}
+	collectionURI := fmt.Sprintf("/api/v1/collection/%v", collection.Id)
+
+	t.Run("can filter their collections by tag", func(t *testing.T) {
+		expectedResponse := ExpectedResponse{
+			1, []struct{ Name string }{{"reading list"}},
+		}
+		Request(testServer.URL, t).
+			Get("/api/v1/collection?tag=golang").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection?tag=golang").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{0}).
+			End()
+	})
+
+	t.Run("cannot update without a name or tags", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", userToken).
+			Send(`{"tags": []}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Please enter valid collection name"}`).
+			End()
+	})
+
+	t.Run("cannot tag the collection of another user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", anotherUserToken).
+			Send(`{"tags": ["python"]}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot edit this collection"}`).
+			End()
+	})
+
+	t.Run("can change the tags without renaming", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", userToken).
+			Send(`{"tags": ["python"], "removedTags": ["golang"]}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct {
+				Message           string
+				AddedTags         []string
+				RemovedTags       []string
+				UpdatedCollection struct{ Name string }
+			}{
+				"Collection Updated Successfully",
+				[]string{"Python"},
+				[]string{"Golang"},
+				struct{ Name string }{"reading list"},
+			}).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection?tag=golang").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{0}).
+			End()
+
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", userToken).
+			Expect(200).
+			Expect(struct{ Collection ExpectedCollection }{ExpectedCollection{
+				"reading list",
+				[]ExpectedTag{{"Databases"}, {"Python"}},
+			}}).
+			End()
+	})
+
+	t.Run("can search collections by tag", func(t *testing.T) {
+		type ExpectedResult struct {
+			Id   int64
+			Name string
+		}
+		Request(testServer.URL, t).
+			Get("/api/v1/search?q=python&type=collections").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct{ Collections []ExpectedResult }{
+				[]ExpectedResult{{collection.Id, "reading list"}},
+			}).
+			End()
+	})
+}
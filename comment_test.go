@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -396,3 +397,309 @@ func TestGetComments(t *testing.T) {
 			End()
 	})
 }
+
+func TestCommentThreads(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	author, authorToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = owner.Id
+	resource := addTestResource(t, testResource)
+
+	testComment := dummyData["testComment1"].(map[string]interface{})
+	testComment["userId"] = author.Id
+	testComment["resourceId"] = resource.Id
+	comment := addTestComment(t, testComment)
+
+	secondComment := dummyData["testComment2"].(map[string]interface{})
+	secondComment["userId"] = owner.Id
+	secondComment["resourceId"] = resource.Id
+	addTestComment(t, secondComment)
+
+	commentURI := fmt.Sprintf("/api/v1/comment/%v", comment.Id)
+	var reply Comment
+
+	// getComments decode the comments of a request for the resource
+	getComments := func(t *testing.T, query string) []Comment {
+		request, _ := http.NewRequest("GET", fmt.Sprintf(
+			"%v/api/v1/comment?resourceId=%v&%v",
+			testServer.URL, resource.Id, query,
+		), nil)
+		request.Header.Set("authorization", ownerToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct{ Comments []Comment }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		return payload.Comments
+	}
+
+	t.Run("cannot reply to a comment on another resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(
+				`{"text": "A reply", "resourceId": %v, "parentId": %v}`,
+				resource.Id+1, comment.Id,
+			)).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"The parent comment does not exist on this resource"}`).
+			End()
+	})
+
+	t.Run("can reply to a comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(
+				`{"text": "A reply", "resourceId": %v, "parentId": %v, "depth": 7}`,
+				resource.Id, comment.Id,
+			)).
+			Expect(200).
+			End()
+
+		err := app.Db.Model(&reply).Where("parent_id = ?", comment.Id).Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if reply.Depth != 1 {
+			t.Fatalf("Expected reply depth 1; Got %v", reply.Depth)
+		}
+	})
+
+	t.Run("cannot nest replies deeper than COMMENT_MAX_DEPTH", func(t *testing.T) {
+		customizeEnvVariables(t, map[string]string{"COMMENT_MAX_DEPTH": "1"})
+		defer os.Unsetenv("COMMENT_MAX_DEPTH")
+
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(
+				`{"text": "A nested reply", "resourceId": %v, "parentId": %v}`,
+				resource.Id, reply.Id,
+			)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Replies cannot be nested any deeper"}`).
+			End()
+	})
+
+	t.Run("can get comments as a tree", func(t *testing.T) {
+		comments := getComments(t, "view=tree")
+		if len(comments) != 2 || comments[0].Id != comment.Id {
+			t.Fatalf("Expected the two top level comments; Got %v", comments)
+		}
+		if replies := comments[0].Replies; len(replies) != 1 || replies[0].Id != reply.Id {
+			t.Fatalf("Expected the reply nested in its parent; Got %v", replies)
+		}
+	})
+
+	t.Run("can get comments as a flattened thread", func(t *testing.T) {
+		comments := getComments(t, "view=thread&limit=2&page=1")
+		if len(comments) != 2 || comments[0].Id != comment.Id || comments[1].Id != reply.Id {
+			t.Fatalf("Expected the comment followed by its reply; Got %v", comments)
+		}
+	})
+
+	t.Run("only the author can edit a comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(commentURI).
+			Set("authorization", ownerToken).
+			Send(`{"text": "Not mine to edit"}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You can only edit your own comments"}`).
+			End()
+	})
+
+	t.Run("editing a comment keeps its previous text", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(commentURI).
+			Set("authorization", authorToken).
+			Send(`{"text": "An edited comment"}`).
+			Expect(200).
+			End()
+
+		var edits []CommentEdit
+		err := app.Db.Model(&edits).Where("comment_id = ?", comment.Id).Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(edits) != 1 || edits[0].Text != comment.Text {
+			t.Fatalf("Expected the previous text in the edit history; Got %v", edits)
+		}
+
+		Request(testServer.URL, t).
+			Get(commentURI+"/edits").
+			Set("authorization", ownerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("others cannot delete a comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(commentURI).
+			Set("authorization", thirdUserToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot delete this comment"}`).
+			End()
+	})
+
+	t.Run("the resource owner can delete a comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(commentURI).
+			Set("authorization", ownerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Comment deleted"}`).
+			End()
+
+		comments := getComments(t, "view=tree")
+		if comments[0].DeletedAt == nil || comments[0].Text != "" {
+			t.Fatalf("Expected a placeholder for the deleted comment; Got %v", comments[0])
+		}
+		if len(comments[0].Replies) != 1 {
+			t.Fatalf("Expected the reply to stay in the thread; Got %v", comments[0].Replies)
+		}
+	})
+
+	t.Run("cannot reply to a deleted comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(
+				`{"text": "A late reply", "resourceId": %v, "parentId": %v}`,
+				resource.Id, comment.Id,
+			)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot reply to a deleted comment"}`).
+			End()
+	})
+}
+
+func TestCommentReactions(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, _ := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	_, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	var comments []Comment
+	for _, title := range []string{"testComment1", "testComment2"} {
+		testComment := dummyData[title].(map[string]interface{})
+		testComment["userId"] = user.Id
+		testComment["resourceId"] = resource.Id
+		comments = append(comments, addTestComment(t, testComment))
+	}
+	reactionsURI := fmt.Sprintf("/api/v1/comment/%v/reactions", comments[1].Id)
+
+	t.Run("cannot give an unknown reaction", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(reactionsURI).
+			Set("authorization", anotherUserToken).
+			Send(`{"reaction": "meh"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"reaction must be one of like, love, laugh, insightful, confused, celebrate"}`).
+			End()
+	})
+
+	t.Run("can like a comment once", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(reactionsURI).
+			Set("authorization", anotherUserToken).
+			Send(`{"reaction": "like"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"likes":1,"message":"Reaction added","reactions":{}}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post(reactionsURI).
+			Set("authorization", anotherUserToken).
+			Send(`{"reaction": "like"}`).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You have already given this reaction"}`).
+			End()
+	})
+
+	t.Run("can give other reactions", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(reactionsURI).
+			Set("authorization", anotherUserToken).
+			Send(`{"reaction": "insightful"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"likes":1,"message":"Reaction added","reactions":{"insightful":1}}`).
+			End()
+	})
+
+	t.Run("comments sorted by likes show the reactions of the user", func(t *testing.T) {
+		request, _ := http.NewRequest("GET", fmt.Sprintf(
+			"%v/api/v1/comment?resourceId=%v&sort=likes",
+			testServer.URL, resource.Id,
+		), nil)
+		request.Header.Set("authorization", anotherUserToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct{ Comments []Comment }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(payload.Comments) != 2 || payload.Comments[0].Id != comments[1].Id {
+			t.Fatalf("Expected the liked comment first; Got %v", payload.Comments)
+		}
+		myReactions := payload.Comments[0].MyReactions
+		if len(myReactions) != 2 || myReactions[0] != "like" || myReactions[1] != "insightful" {
+			t.Fatalf("Expected the reactions of the user; Got %v", myReactions)
+		}
+	})
+
+	t.Run("can unlike a comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(reactionsURI+"/like").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"likes":0,"message":"Reaction removed","reactions":{"insightful":1}}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(reactionsURI+"/like").
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You have not given this reaction"}`).
+			End()
+	})
+}
@@ -0,0 +1,254 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+)
+
+func TestUnfollowBlockAndMute(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	thirdUser, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	addTestConnection(t, map[string]interface{}{
+		"initiatorId": anotherUser.Id,
+		"recipientId": user.Id,
+	})
+	addTestConnection(t, map[string]interface{}{
+		"initiatorId": thirdUser.Id,
+		"recipientId": user.Id,
+	})
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	t.Run("can unfollow a followed user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/connection/%v", user.Id)).
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Unfollowed user successfully"}`).
+			End()
+	})
+
+	t.Run("cannot unfollow a user who is not followed", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/connection/%v", user.Id)).
+			Set("authorization", thirdUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not connected with this user"}`).
+			End()
+	})
+
+	t.Run("cannot block yourself", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection/block").
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, user.Id)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot perform this action on yourself"}`).
+			End()
+	})
+
+	t.Run("can block a follower", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection/block").
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, anotherUser.Id)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Blocked user successfully"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/connection/favorites").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"connections":null,"totalCount":0}`).
+			End()
+	})
+
+	t.Run("blocked user cannot follow again", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection").
+			Set("authorization", anotherUserToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, user.Id)).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot connect with this user"}`).
+			End()
+	})
+
+	t.Run("blocked user cannot comment", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", anotherUserToken).
+			Send(fmt.Sprintf(`{"resourceId": %v, "text": "Hi"}`, resource.Id)).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot comment on this resource"}`).
+			End()
+	})
+
+	t.Run("can mute a user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection/mute").
+			Set("authorization", thirdUserToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, user.Id)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Muted user successfully"}`).
+			End()
+	})
+
+	t.Run("can unmute a muted user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/connection/mute/%v", user.Id)).
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Unmuted user successfully"}`).
+			End()
+	})
+}
+
+func TestFollowRequests(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	thirdUser, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["followersResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	t.Run("can make an account private", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/user/profile").
+			Set("authorization", userToken).
+			Send(`{"private": true}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Profile Updated successfully","updatedProfile":{"private":true}}`).
+			End()
+	})
+
+	t.Run("following a private account sends a request", func(t *testing.T) {
+		for _, token := range []string{anotherUserToken, thirdUserToken} {
+			Request(testServer.URL, t).
+				Post("/api/v1/connection").
+				Set("authorization", token).
+				Send(fmt.Sprintf(`{"userId": %v}`, user.Id)).
+				Expect(200).
+				Expect("Content-Type", "application/json").
+				Expect(`{"message":"follow request sent"}`).
+				End()
+		}
+	})
+
+	t.Run("pending followers cannot access followers resources", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/resource/%v", resource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("can list incoming and outgoing requests", func(t *testing.T) {
+		type ExpectedRequest struct {
+			InitiatorId int64
+			RecipientId int64
+			Status      string
+		}
+		type ExpectedResponse struct {
+			Requests   []ExpectedRequest
+			TotalCount int
+		}
+		Request(testServer.URL, t).
+			Get("/api/v1/connection/requests/incoming").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedResponse{[]ExpectedRequest{
+				{thirdUser.Id, user.Id, "pending"},
+				{anotherUser.Id, user.Id, "pending"},
+			}, 2}).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/connection/requests/outgoing").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedResponse{[]ExpectedRequest{
+				{anotherUser.Id, user.Id, "pending"},
+			}, 1}).
+			End()
+	})
+
+	t.Run("can accept a follow request", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/connection/requests/%v", anotherUser.Id)).
+			Set("authorization", userToken).
+			Send(`{"accept": true}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Follow request accepted"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/resource/%v", resource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("can reject a follow request", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/connection/requests/%v", thirdUser.Id)).
+			Set("authorization", userToken).
+			Send(`{"accept": false}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Follow request rejected"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/connection/requests/%v", thirdUser.Id)).
+			Set("authorization", userToken).
+			Send(`{"accept": true}`).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"There is no pending request from this user"}`).
+			End()
+	})
+}
@@ -0,0 +1,293 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestCollectionDiscovery(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	follower, followerToken := addTestUser(t, anotherTestUser)
+
+	var resources []Resource
+	for _, key := range []string{"testResource", "privateResource", "followersResource"} {
+		testResource := dummyData[key].(map[string]interface{})
+		testResource["userId"] = owner.Id
+		resources = append(resources, addTestResource(t, testResource))
+	}
+
+	testCollection := dummyData["collection1"].(map[string]interface{})
+	testCollection["userId"] = owner.Id
+	collection := addTestCollection(t, testCollection)
+	collectionURI := fmt.Sprintf("/api/v1/collection/%v", collection.Id)
+	addURI := fmt.Sprintf("/api/v1/collection/add/%v", collection.Id)
+
+	Request(testServer.URL, t).
+		Post(addURI).
+		Set("authorization", ownerToken).
+		Send(fmt.Sprintf(`{"ResourceId": %v}`, resources[1].Id)).
+		Expect(200).
+		End()
+
+	// setPrivacy change the privacy of the collection and get its share token
+	setPrivacy := func(t *testing.T, privacy string) string {
+		request, _ := http.NewRequest(
+			"PUT", testServer.URL+collectionURI,
+			strings.NewReader(fmt.Sprintf(`{"privacy": %q}`, privacy)),
+		)
+		request.Header.Set("authorization", ownerToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200; Got status %v", response.StatusCode)
+		}
+		var payload struct{ ShareToken string }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		return payload.ShareToken
+	}
+
+	t.Run("collections are private by default", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", followerToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Collection not found"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/public").
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{0}).
+			End()
+	})
+
+	t.Run("cannot set an invalid privacy", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(collectionURI).
+			Set("authorization", ownerToken).
+			Send(`{"privacy": "friends"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"privacy must be one of private, unlisted, public"}`).
+			End()
+	})
+
+	t.Run("unlisted collections can be viewed through their link", func(t *testing.T) {
+		shareToken := setPrivacy(t, "unlisted")
+		if shareToken == "" {
+			t.Fatal("Expected a share token")
+		}
+		sharedURI := "/api/v1/collection/shared/" + shareToken
+
+		Request(testServer.URL, t).
+			Get(sharedURI).
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct {
+				TotalCount int
+				Collection struct{ Name string }
+			}{0, struct{ Name string }{"first collection"}}).
+			End()
+
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", followerToken).
+			Expect(404).
+			End()
+
+		setPrivacy(t, "private")
+		Request(testServer.URL, t).
+			Get(sharedURI).
+			Set("authorization", followerToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Collection not found"}`).
+			End()
+	})
+
+	t.Run("public collections can be browsed", func(t *testing.T) {
+		setPrivacy(t, "public")
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/public").
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct {
+				TotalCount  int
+				Collections []struct{ Name string }
+			}{1, []struct{ Name string }{{"first collection"}}}).
+			End()
+
+		Request(testServer.URL, t).
+			Get(collectionURI).
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{0}).
+			End()
+	})
+
+	t.Run("can follow a public collection", func(t *testing.T) {
+		followPayload := fmt.Sprintf(`{"collectionId": %v}`, collection.Id)
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/follow").
+			Set("authorization", ownerToken).
+			Send(followPayload).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot follow your own collection"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/follow").
+			Set("authorization", followerToken).
+			Send(followPayload).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collection followed"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/follow").
+			Set("authorization", followerToken).
+			Send(followPayload).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You already follow this collection"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/following").
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{1}).
+			End()
+	})
+
+	t.Run("followers are notified of resources they can access", func(t *testing.T) {
+		for _, resource := range []Resource{resources[0], resources[2]} {
+			Request(testServer.URL, t).
+				Post(addURI).
+				Set("authorization", ownerToken).
+				Send(fmt.Sprintf(`{"ResourceId": %v}`, resource.Id)).
+				Expect(200).
+				End()
+		}
+		var notifications []Notification
+		err := app.Db.Model(&notifications).
+			Where("user_id = ? AND type = ?", follower.Id, FollowedCollectionNotification).
+			Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(notifications) != 1 || notifications[0].ResourceId != resources[0].Id {
+			t.Fatalf("Expected a notification of resource %v; Got %+v",
+				resources[0].Id, notifications)
+		}
+	})
+
+	t.Run("can fork a public collection", func(t *testing.T) {
+		forkPayload := fmt.Sprintf(`{"collectionId": %v}`, collection.Id)
+		request, _ := http.NewRequest(
+			"POST", testServer.URL+"/api/v1/collection/fork",
+			strings.NewReader(forkPayload),
+		)
+		request.Header.Set("authorization", followerToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if response.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201; Got status %v", response.StatusCode)
+		}
+		var payload struct{ Collection Collection }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		fork := payload.Collection
+		if fork.Name != "first collection (copy)" || fork.Privacy != "private" ||
+			fork.ForkedFromId != collection.Id || fork.UserId != follower.Id {
+			t.Fatalf("Expected a private copy of the collection; Got %+v", fork)
+		}
+
+		// the private resource of the owner is left out
+		var copied []ResourceCollection
+		err = app.Db.Model(&copied).
+			Where("collection_id = ?", fork.Id).
+			Order("position ASC").
+			Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(copied) != 1 || copied[0].ResourceId != resources[0].Id ||
+			copied[0].Position != 1 {
+			t.Fatalf("Expected resource %v at position 1; Got %+v",
+				resources[0].Id, copied)
+		}
+
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/fork").
+			Set("authorization", followerToken).
+			Send(forkPayload).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"A collection exists with this name"}`).
+			End()
+	})
+
+	t.Run("cannot fork a private collection", func(t *testing.T) {
+		setPrivacy(t, "private")
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/fork").
+			Set("authorization", followerToken).
+			Send(fmt.Sprintf(`{"collectionId": %v, "name": "mine"}`, collection.Id)).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Collection not found"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/following").
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect(struct{ TotalCount int }{0}).
+			End()
+	})
+
+	t.Run("can unfollow a collection", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/collection/follow/%v", collection.Id)).
+			Set("authorization", followerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Collection unfollowed"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/collection/follow/%v", collection.Id)).
+			Set("authorization", followerToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You do not follow this collection"}`).
+			End()
+	})
+}
@@ -0,0 +1,94 @@
+package main_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+)
+
+func TestGetFeed(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedItem struct {
+		Type       string
+		UserId     int64
+		ResourceId int64
+	}
+	type ExpectedResponse struct {
+		Feed []ExpectedItem
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	thirdUser, _ := addTestUser(t, thirdTestUser)
+
+	addTestConnection(t, map[string]interface{}{
+		"initiatorId": anotherUser.Id,
+		"recipientId": user.Id,
+	})
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	publicResource := addTestResource(t, testResource)
+
+	testResource = dummyData["privateResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	addTestResource(t, testResource)
+
+	testResource = dummyData["followersResource"].(map[string]interface{})
+	testResource["userId"] = thirdUser.Id
+	unfollowedResource := addTestResource(t, testResource)
+
+	testComment := dummyData["testComment1"].(map[string]interface{})
+	testComment["userId"] = user.Id
+	testComment["resourceId"] = publicResource.Id
+	addTestComment(t, testComment)
+
+	testComment = dummyData["testComment2"].(map[string]interface{})
+	testComment["userId"] = thirdUser.Id
+	testComment["resourceId"] = unfollowedResource.Id
+	addTestComment(t, testComment)
+
+	t.Run("gets activities of followed users newest first", func(t *testing.T) {
+		expectedResponse := ExpectedResponse{[]ExpectedItem{
+			{"comment", user.Id, publicResource.Id},
+			{"resource", user.Id, publicResource.Id},
+		}}
+		Request(testServer.URL, t).
+			Get("/api/v1/feed").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+	})
+
+	t.Run("gets an empty feed when not following anyone", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/feed").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"feed":null,"nextCursor":""}`).
+			End()
+	})
+
+	t.Run("cannot get feed with an invalid cursor", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/feed?cursor=invalid").
+			Set("authorization", anotherUserToken).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Invalid feed cursor"}`).
+			End()
+	})
+}
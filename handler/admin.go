@@ -0,0 +1,399 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errUserNotFound  = errors.New("User does not exist")
+	errOutranked     = errors.New("You cannot perform this action on this user")
+	errTargetMissing = errors.New("The content does not exist")
+)
+
+// recordAdminAction record an action of the moderator or admin of a request
+// in the moderation log and the audit log
+func (h *Handler) recordAdminAction(
+	db orm.DB, r *http.Request,
+	action, targetType string, targetId int64, details map[string]interface{},
+) error {
+	adminId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	_, err := db.Model(&AdminAction{
+		AdminId:    int64(adminId),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Details:    details,
+	}).Insert()
+	if err != nil {
+		return err
+	}
+	event := auditEvent(r, action, targetType, targetId)
+	event.Details = details
+	return h.Audit.RecordTx(db, event)
+}
+
+// lockOutrankedUser lock the user in the userId route var, if the user of
+// the request has a more powerful role
+func lockOutrankedUser(tx *pg.Tx, r *http.Request) (*User, error) {
+	userId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	return lockUserOutrankedBy(tx, r, userId)
+}
+
+// lockUserOutrankedBy lock a user, if the user of the request has a more
+// powerful role
+func lockUserOutrankedBy(tx *pg.Tx, r *http.Request, userId int64) (*User, error) {
+	user := User{Id: userId}
+	err := tx.Model(&user).
+		Column("id", "username", "role", "suspended_until", "banned_at").
+		WherePK().
+		For("UPDATE").
+		Select()
+	if err == pg.ErrNoRows {
+		return nil, errUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	role, _ := context.Get(r, "decoded").(jwt.MapClaims)["role"].(string)
+	if RoleRank(role) <= RoleRank(user.Role) {
+		return nil, errOutranked
+	}
+	return &user, nil
+}
+
+// respondWithAdminError respond to an error of an admin action
+func respondWithAdminError(w http.ResponseWriter, err error) {
+	switch err {
+	case errUserNotFound, errTargetMissing:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errOutranked:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// GetUsers list and search users, optionally by role and account status
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	var users []User
+	query := h.Db.Model(&users).
+		Column(
+			"id", "username", "email", "role", "verified_at",
+			"suspended_until", "banned_at", "created_at",
+		)
+	if q := strings.TrimSpace(queryValues.Get("q")); q != "" {
+		pattern := "%" + q + "%"
+		query = query.Where("username ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+	if role := queryValues.Get("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch queryValues.Get("status") {
+	case "":
+	case "active":
+		query = query.Where("banned_at IS NULL").
+			Where("suspended_until IS NULL OR suspended_until <= now()")
+	case "suspended":
+		query = query.Where("banned_at IS NULL AND suspended_until > now()")
+	case "banned":
+		query = query.Where("banned_at IS NOT NULL")
+	case "unverified":
+		query = query.Where("verified_at IS NULL")
+	default:
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"status must be active, suspended, banned or unverified",
+		)
+		return
+	}
+	count, err := query.
+		Order("id ASC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"users":      users,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// suspendUser suspend a user until a date and end their sessions, or lift
+// the suspension when until is nil
+func suspendUser(db orm.DB, userId int64, until *time.Time) error {
+	_, err := db.Model(&User{}).
+		Set("suspended_until = ?", until).
+		Where("id = ?", userId).
+		Update()
+	if err != nil || until == nil {
+		return err
+	}
+	return revokeRefreshTokens(db, userId)
+}
+
+// SuspendUser suspend a user until a date, or lift the suspension when no
+// date is given
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Until, Reason string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	var until *time.Time
+	if payload.Until != "" {
+		date, err := utils.ParseDate(payload.Until)
+		if err != nil || !date.After(time.Now()) {
+			utils.RespondWithError(
+				w, http.StatusBadRequest,
+				"until must be a future date",
+			)
+			return
+		}
+		until = &date
+	}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		user, err := lockOutrankedUser(tx, r)
+		if err != nil {
+			return err
+		}
+		if err := suspendUser(tx, user.Id, until); err != nil {
+			return err
+		}
+		action := "unsuspendUser"
+		if until != nil {
+			action = "suspendUser"
+		}
+		return h.recordAdminAction(tx, r, action, "user", user.Id, map[string]interface{}{
+			"until":  until,
+			"reason": payload.Reason,
+		})
+	})
+	if err != nil {
+		respondWithAdminError(w, err)
+	} else if until != nil {
+		utils.RespondWithSuccess(w, http.StatusOK, "User suspended", "message")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "User suspension lifted", "message")
+	}
+}
+
+// BanUser ban or unban a user
+func (h *Handler) BanUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct {
+		Banned *bool
+		Reason string
+	}
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Banned == nil {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"Banned must be true or false",
+		)
+		return
+	}
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		user, err := lockOutrankedUser(tx, r)
+		if err != nil {
+			return err
+		}
+		var bannedAt *time.Time
+		action := "unbanUser"
+		if *payload.Banned {
+			now := time.Now()
+			bannedAt, action = &now, "banUser"
+			if err := revokeRefreshTokens(tx, user.Id); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Model(user).
+			Set("banned_at = ?", bannedAt).
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		return h.recordAdminAction(tx, r, action, "user", user.Id, map[string]interface{}{
+			"reason": payload.Reason,
+		})
+	})
+	if err != nil {
+		respondWithAdminError(w, err)
+	} else if *payload.Banned {
+		utils.RespondWithSuccess(w, http.StatusOK, "User banned", "message")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "User unbanned", "message")
+	}
+}
+
+// UpdateUserRole change the role of a user
+func (h *Handler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Role string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	if RoleRank(payload.Role) < 0 {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"role must be one of "+strings.Join(Roles, ", "),
+		)
+		return
+	}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		user, err := lockOutrankedUser(tx, r)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model(user).
+			Set("role = ?", payload.Role).
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		return h.recordAdminAction(tx, r, "changeRole", "user", user.Id, map[string]interface{}{
+			"from": user.Role,
+			"to":   payload.Role,
+		})
+	})
+	if err != nil {
+		respondWithAdminError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "User role updated", "message")
+	}
+}
+
+// forceDelete delete content of any user and record it as action
+func (h *Handler) forceDelete(
+	w http.ResponseWriter, r *http.Request,
+	model interface{}, action, targetType, idVar string,
+) {
+	targetId, _ := strconv.ParseInt(mux.Vars(r)[idVar], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var ownerId int64
+		res, err := tx.Model(model).
+			Where("id = ?", targetId).
+			Returning("user_id").
+			Delete(pg.Scan(&ownerId))
+		if err == pg.ErrNoRows || (err == nil && res.RowsAffected() == 0) {
+			return errTargetMissing
+		} else if err != nil {
+			return err
+		}
+		return h.recordAdminAction(tx, r, action, targetType, targetId, map[string]interface{}{
+			"ownerId": ownerId,
+			"reason":  r.URL.Query().Get("reason"),
+		})
+	})
+	if err != nil {
+		respondWithAdminError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Content deleted", "message")
+	}
+}
+
+// ForceDeleteResource delete the resource of any user
+func (h *Handler) ForceDeleteResource(w http.ResponseWriter, r *http.Request) {
+	h.forceDelete(w, r, &Resource{}, "deleteResource", "resource", "resourceId")
+}
+
+// ForceDeleteComment delete the comment of any user; like the comments
+// deleted by their author, it stays as a placeholder so its replies keep
+// their thread
+func (h *Handler) ForceDeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var comment Comment
+		err := tx.Model(&comment).
+			Column("id", "user_id").
+			Where("id = ? AND deleted_at IS NULL", commentId).
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errTargetMissing
+		} else if err != nil {
+			return err
+		}
+		if err := softDeleteComment(tx, comment.Id); err != nil {
+			return err
+		}
+		return h.recordAdminAction(tx, r, "deleteComment", "comment", comment.Id, map[string]interface{}{
+			"ownerId": comment.UserId,
+			"reason":  r.URL.Query().Get("reason"),
+		})
+	})
+	if err != nil {
+		respondWithAdminError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Content deleted", "message")
+	}
+}
+
+// GetAdminActions get the recorded actions of moderators and admins, newest
+// first, optionally filtered by admin, action and target
+func (h *Handler) GetAdminActions(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	var actions []AdminAction
+	query := h.Db.Model(&actions)
+	for param, column := range map[string]string{
+		"adminId":  "admin_id",
+		"targetId": "target_id",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				utils.RespondWithError(
+					w, http.StatusBadRequest,
+					param+" must be a number",
+				)
+				return
+			}
+			query = query.Where("? = ?", pg.F(column), id)
+		}
+	}
+	for param, column := range map[string]string{
+		"action":     "action",
+		"targetType": "target_type",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			query = query.Where("? = ?", pg.F(column), value)
+		}
+	}
+	count, err := query.
+		Order("created_at DESC", "id DESC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"actions":    actions,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+)
+
+// clientIp get the address of the client of a request; X-Forwarded-For is
+// only trusted behind a proxy, when TRUST_PROXY is true
+func clientIp(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditEvent build the audit event of a request, with the user of the
+// request as actor when it is authenticated
+func auditEvent(r *http.Request, action, targetType string, targetId int64) *AuditEvent {
+	event := &AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Ip:         clientIp(r),
+		UserAgent:  r.UserAgent(),
+	}
+	if claims, ok := context.Get(r, "decoded").(jwt.MapClaims); ok {
+		if userId, ok := claims["userId"].(float64); ok {
+			event.ActorId = int64(userId)
+		}
+	}
+	return event
+}
+
+// GetAuditEvents get the audit log, newest first, optionally filtered by
+// actor, action, target, address and date
+func (h *Handler) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	var events []AuditEvent
+	query := h.Db.Model(&events)
+	for param, column := range map[string]string{
+		"actorId":  "actor_id",
+		"targetId": "target_id",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				utils.RespondWithError(
+					w, http.StatusBadRequest,
+					param+" must be a number",
+				)
+				return
+			}
+			query = query.Where("? = ?", pg.F(column), id)
+		}
+	}
+	for param, column := range map[string]string{
+		"action":     "action",
+		"targetType": "target_type",
+		"ip":         "ip",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			query = query.Where("? = ?", pg.F(column), value)
+		}
+	}
+	for param, condition := range map[string]string{
+		"from": "created_at >= ?",
+		"to":   "created_at <= ?",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			date, err := utils.ParseDate(value)
+			if err != nil {
+				utils.RespondWithError(
+					w, http.StatusBadRequest,
+					param+" must be a date",
+				)
+				return
+			}
+			query = query.Where(condition, date)
+		}
+	}
+	count, err := query.
+		Order("created_at DESC", "id DESC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"events":     events,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("Invalid refresh token")
+	errRevokedRefreshToken = errors.New("Refresh token has been revoked")
+	errExpiredRefreshToken = errors.New("Refresh token has expired")
+)
+
+// createRefreshToken store a new refresh token for a user and return it;
+// only a hash of the token is stored
+func createRefreshToken(db orm.DB, userId int64, userAgent string) (string, error) {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	lifetime := TokenLifetime("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	_, err = db.Model(&RefreshToken{
+		UserId:    userId,
+		TokenHash: utils.HashToken(token),
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(lifetime),
+	}).Insert()
+	return token, err
+}
+
+// revokeRefreshTokens revoke every active refresh token of a user
+func revokeRefreshTokens(db orm.DB, userId int64) error {
+	_, err := db.Model(&RefreshToken{}).
+		Set("revoked_at = ?", time.Now()).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update()
+	return err
+}
+
+// respondWithTokens respond with a new access token and refresh token
+func (h *Handler) respondWithTokens(
+	w http.ResponseWriter, r *http.Request, user *User, status int,
+) {
+	token, err := user.GenerateToken()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	refreshToken, err := createRefreshToken(h.Db, user.Id, r.UserAgent())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	payload := map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"message":      "Authentication successful",
+	}
+	utils.RespondWithJson(w, status, payload)
+}
+
+// RefreshToken exchange a refresh token for a new access token and refresh
+// token; reusing a rotated token revokes every session of its user
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ RefreshToken string }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.RefreshToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid refreshToken is required",
+		)
+		return
+	}
+	var user User
+	var refreshToken string
+	var restriction error
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var stored RefreshToken
+		err := tx.Model(&stored).
+			Where("token_hash = ?", utils.HashToken(payload.RefreshToken)).
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errInvalidRefreshToken
+		} else if err != nil {
+			return err
+		}
+		if stored.RevokedAt != nil {
+			// the token was stolen or replayed, so end every session
+			return revokeRefreshTokens(tx, stored.UserId)
+		}
+		if stored.ExpiresAt.Before(time.Now()) {
+			return errExpiredRefreshToken
+		}
+		_, err = tx.Model(&stored).
+			Set("revoked_at = ?", time.Now()).
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		user.Id = stored.UserId
+		if err := tx.Select(&user); err != nil {
+			return err
+		}
+		if restriction = user.Restriction(); restriction != nil {
+			return restriction
+		}
+		refreshToken, err = createRefreshToken(tx, user.Id, r.UserAgent())
+		return err
+	})
+	if err == nil && user.Id == 0 {
+		err = errRevokedRefreshToken
+	}
+	switch err {
+	case nil:
+	case errInvalidRefreshToken, errRevokedRefreshToken, errExpiredRefreshToken:
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	case restriction:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	token, err := user.GenerateToken()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	responsePayload := map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"message":      "Token refreshed",
+	}
+	utils.RespondWithJson(w, http.StatusOK, responsePayload)
+}
+
+// Logout revoke a refresh token, or with allDevices every session of its
+// user including their access tokens
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct {
+		RefreshToken string
+		AllDevices   bool
+	}
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.RefreshToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid refreshToken is required",
+		)
+		return
+	}
+	var stored RefreshToken
+	err = h.Db.Model(&stored).
+		Where("token_hash = ?", utils.HashToken(payload.RefreshToken)).
+		Where("revoked_at IS NULL").
+		Select()
+	if err == pg.ErrNoRows {
+		utils.RespondWithError(w, http.StatusUnauthorized, errInvalidRefreshToken.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if payload.AllDevices {
+		err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+			_, err := tx.Model(&User{}).
+				Set("logged_out_at = ?", time.Now()).
+				Where("id = ?", stored.UserId).
+				Update()
+			if err != nil {
+				return err
+			}
+			return revokeRefreshTokens(tx, stored.UserId)
+		})
+	} else {
+		_, err = h.Db.Model(&stored).
+			Set("revoked_at = ?", time.Now()).
+			WherePK().
+			Update()
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Logged out successfully", "message")
+	}
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errAlreadyCollaborator = errors.New("User is already a collaborator or invited")
+	errNotCollaborator     = errors.New("User is not a collaborator of this collection")
+	errCannotInvite        = errors.New("You cannot invite this user")
+)
+
+// respondWithCollaboratorError respond to an error managing collaborators
+func respondWithCollaboratorError(w http.ResponseWriter, err error) {
+	switch err {
+	case errNotCollaborator, errUserNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errCannotInvite:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errAlreadyCollaborator:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		respondWithCollectionError(w, err)
+	}
+}
+
+// collaboratorRole decode the role of a collaborator
+func collaboratorRole(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	defer r.Body.Close()
+	var payload struct {
+		Role   string
+		UserId int64
+	}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if !utils.Contains(CollaboratorRoles, payload.Role) {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"role must be one of "+strings.Join(CollaboratorRoles, ", "),
+		)
+		return "", 0, false
+	}
+	return payload.Role, payload.UserId, true
+}
+
+// InviteCollaborator invite a user to view or edit a collection of the user
+func (h *Handler) InviteCollaborator(w http.ResponseWriter, r *http.Request) {
+	role, inviteeId, ok := collaboratorRole(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	if inviteeId == 0 || inviteeId == int64(userId) {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid userId is required",
+		)
+		return
+	}
+	collaborator := CollectionCollaborator{
+		UserId:    inviteeId,
+		InviterId: int64(userId),
+		Role:      role,
+		Status:    PendingInvitation,
+	}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		collection, err := lockRouteCollection(tx, r, true)
+		if err != nil {
+			return err
+		}
+		collaborator.CollectionId = collection.Id
+		if blocked, err := h.isBlocked(int64(userId), inviteeId); err != nil {
+			return err
+		} else if blocked {
+			return errCannotInvite
+		}
+		res, err := tx.Model(&collaborator).OnConflict("DO NOTHING").Insert()
+		if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23503" {
+			return errUserNotFound
+		} else if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errAlreadyCollaborator
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithCollaboratorError(w, err)
+		return
+	}
+	h.notify(Notification{
+		UserId:       inviteeId,
+		ActorId:      int64(userId),
+		Type:         InvitationNotification,
+		CollectionId: collaborator.CollectionId,
+	})
+	utils.RespondWithSuccess(w, http.StatusCreated, "Invitation sent", "message")
+}
+
+// UpdateCollaborator change the role of a collaborator of a collection of
+// the user
+func (h *Handler) UpdateCollaborator(w http.ResponseWriter, r *http.Request) {
+	role, _, ok := collaboratorRole(w, r)
+	if !ok {
+		return
+	}
+	collaboratorId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		collection, err := lockRouteCollection(tx, r, true)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Model(&CollectionCollaborator{}).
+			Set("role = ?", role).
+			Set("updated_at = now()").
+			Where("collection_id = ? AND user_id = ?", collection.Id, collaboratorId).
+			Update()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotCollaborator
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithCollaboratorError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Collaborator role updated", "message")
+	}
+}
+
+// RemoveCollaborator remove a collaborator or invitation from a collection
+// of the user; collaborators can also remove themselves
+func (h *Handler) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	collaboratorId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		if collaboratorId != int64(userId) {
+			if _, err := lockRouteCollection(tx, r, true); err != nil {
+				return err
+			}
+		}
+		res, err := tx.Model(&CollectionCollaborator{}).
+			Where("collection_id = ? AND user_id = ?", collectionId, collaboratorId).
+			Delete()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotCollaborator
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithCollaboratorError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Collaborator removed", "message")
+	}
+}
+
+// GetCollaborators get the collaborators and pending invitations of a
+// collection the user owns or collaborates on
+func (h *Handler) GetCollaborators(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	_, role, err := collectionAccess(h.Db, collectionId, int64(userId), false)
+	if err == nil && role == "" {
+		err = errCollectionNotFound
+	}
+	var collaborators []CollectionCollaborator
+	count := 0
+	if err == nil {
+		count, err = h.Db.Model(&collaborators).
+			Column(
+				"collection_collaborator.user_id",
+				"collection_collaborator.role",
+				"collection_collaborator.status",
+				"collection_collaborator.created_at",
+				"User.id",
+				"User.username",
+			).
+			Where("collection_collaborator.collection_id = ?", collectionId).
+			Order("collection_collaborator.created_at ASC").
+			Apply(orm.Pagination(r.URL.Query())).
+			SelectAndCount()
+	}
+	if err != nil {
+		respondWithCollaboratorError(w, err)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":    count,
+			"collaborators": collaborators,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetInvitations get the pending invitations of the user to collaborate on
+// collections
+func (h *Handler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var invitations []CollectionCollaborator
+	count, err := h.Db.Model(&invitations).
+		Column(
+			"collection_collaborator.collection_id",
+			"collection_collaborator.role",
+			"collection_collaborator.created_at",
+			"Collection.id",
+			"Collection.name",
+			"Inviter.id",
+			"Inviter.username",
+		).
+		Where("collection_collaborator.user_id = ?", int64(userId)).
+		Where("collection_collaborator.status = ?", PendingInvitation).
+		Order("collection_collaborator.created_at DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":  count,
+			"invitations": invitations,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// RespondToInvitation accept or decline an invitation to collaborate on a
+// collection
+func (h *Handler) RespondToInvitation(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	var payload struct{ Accept *bool }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Accept == nil {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"Accept must be true or false",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	query := h.Db.Model(&CollectionCollaborator{}).
+		Where(
+			"collection_id = ? AND user_id = ? AND status = ?",
+			collectionId, int64(userId), PendingInvitation,
+		)
+	var res orm.Result
+	if *payload.Accept {
+		res, err = query.
+			Set("status = ?", AcceptedInvitation).
+			Set("updated_at = now()").
+			Update()
+	} else {
+		res, err = query.Delete()
+	}
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else if res.RowsAffected() == 0 {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"There is no pending invitation to this collection",
+		)
+	} else if *payload.Accept {
+		utils.RespondWithSuccess(w, http.StatusOK, "Invitation accepted", "message")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Invitation declined", "message")
+	}
+}
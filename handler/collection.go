@@ -4,9 +4,11 @@ import (
 	. "WeKnow_api/model"
 	utils "WeKnow_api/utilities"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-pg/pg"
@@ -15,24 +17,158 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// CreateCollectionEndPoint create a new collection
+var (
+	errCollectionNotFound  = errors.New("Collection not found")
+	errCollectionForbidden = errors.New("You cannot edit this collection")
+	errNotInCollection     = errors.New("Resource is not in this collection")
+	errInvalidPosition     = errors.New("position must be a whole number from 1")
+)
+
+// collectionOwner the role of the owner of a collection, besides the roles
+// of collaborators
+const collectionOwner = "owner"
+
+// collectionAccess get a collection and the role of a user on it: owner,
+// the role of an accepted collaborator, or none; lock locks the collection
+func collectionAccess(
+	db orm.DB, collectionId, userId int64, lock bool,
+) (*Collection, string, error) {
+	collection := Collection{Id: collectionId}
+	query := db.Model(&collection).WherePK()
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Select(); err == pg.ErrNoRows {
+		return nil, "", errCollectionNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	if collection.UserId == userId {
+		return &collection, collectionOwner, nil
+	}
+	var role string
+	err := db.Model(&CollectionCollaborator{}).
+		Column("role").
+		Where("collection_id = ? AND user_id = ?", collectionId, userId).
+		Where("status = ?", AcceptedInvitation).
+		Select(pg.Scan(&role))
+	if err == pg.ErrNoRows {
+		err = nil
+	}
+	return &collection, role, err
+}
+
+// lockEditableCollection lock a collection, if the user can edit it; only
+// the owner can when ownerOnly
+func lockEditableCollection(
+	tx *pg.Tx, collectionId, userId int64, ownerOnly bool,
+) (*Collection, error) {
+	collection, role, err := collectionAccess(tx, collectionId, userId, true)
+	if err != nil {
+		return nil, err
+	}
+	if role == collectionOwner || (role == EditorCollaborator && !ownerOnly) {
+		return collection, nil
+	}
+	return nil, errCollectionForbidden
+}
+
+// lockRouteCollection lock the collection in the collectionId route var, if
+// the user of the request can edit it; only the owner can when ownerOnly
+func lockRouteCollection(tx *pg.Tx, r *http.Request, ownerOnly bool) (*Collection, error) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	return lockEditableCollection(tx, collectionId, int64(userId), ownerOnly)
+}
+
+// collectionViewable whether a user with role on a collection, if any, can
+// view it
+func collectionViewable(collection *Collection, role string) bool {
+	return role != "" ||
+		(collection.Privacy == PublicCollection && collection.HiddenAt == nil)
+}
+
+// setShareToken give an unlisted collection a share token if it has none,
+// and take it from a private collection so the link stops working
+func setShareToken(collection *Collection) error {
+	var err error
+	if collection.Privacy == PrivateCollection {
+		collection.ShareToken = ""
+	} else if collection.Privacy == UnlistedCollection && collection.ShareToken == "" {
+		collection.ShareToken, err = utils.GenerateRandomToken()
+	}
+	return err
+}
+
+// tagCollection attach the tags added by the request to a collection and
+// detach the removed tags; it returns the titles of both
+func tagCollection(db orm.DB, r *http.Request, collectionId int64) ([]string, []string, error) {
+	var addedTagTitles, removedTagTitles []string
+	if tags, Ok := context.GetOk(r, "tags"); Ok {
+		var collectionTags []*CollectionTag
+		for _, tag := range tags.([]interface{}) {
+			collectionTags = append(collectionTags, &CollectionTag{
+				TagId:        tag.(*Tag).Id,
+				CollectionId: collectionId,
+			})
+			addedTagTitles = append(addedTagTitles, tag.(*Tag).Title)
+		}
+		_, err := db.Model(&collectionTags).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if removedTags, Ok := context.GetOk(r, "removed_tags"); Ok {
+		var tagIds []int64
+		for _, tag := range removedTags.([]interface{}) {
+			tagIds = append(tagIds, tag.(*Tag).Id)
+			removedTagTitles = append(removedTagTitles, tag.(*Tag).Title)
+		}
+		_, err := db.Model(&CollectionTag{}).
+			Where("collection_id = ? AND tag_id IN (?)", collectionId, pg.In(tagIds)).
+			Delete()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return addedTagTitles, removedTagTitles, nil
+}
+
+// CreateCollectionEndPoint create a new collection, with the tags in the
+// request
 func (h *Handler) CreateCollectionEndPoint(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	collection := &Collection{}
+	var payload struct {
+		Collection
+		Tags []string
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(collection); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	} else {
+		collection := &payload.Collection
 		decodedClaims := context.Get(r, "decoded")
 		userId := decodedClaims.(jwt.MapClaims)["userId"].(float64)
 		if err := utils.ValidateNewCollection(collection); err == nil {
 			collection.UserId = int64(userId)
+			collection.ForkedFromId, collection.ForkedFrom = 0, nil
+			if err := setShareToken(collection); err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+				return
+			}
 			if err := h.Db.Insert(collection); err != nil {
 				utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 				return
 			}
+			if _, _, err := tagCollection(h.Db, r, collection.Id); err != nil {
+				utils.RespondWithError(
+					w, http.StatusInternalServerError,
+					"Oops! we couldn't attach tags to the collection",
+				)
+				return
+			}
 
 			utils.RespondWithSuccess(w, http.StatusCreated, fmt.Sprintf("%s collection was created successfully", string(collection.Name)), "message")
 		} else {
@@ -42,19 +178,36 @@ func (h *Handler) CreateCollectionEndPoint(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// GetAllCollections get the collections of the user and the collections
+// shared with the user, optionally only those with a tag
 func (h *Handler) GetAllCollections(w http.ResponseWriter, r *http.Request) {
 
 	decodedClaims := context.Get(r, "decoded")
 	userId := decodedClaims.(jwt.MapClaims)["userId"].(float64)
+	queryValues := r.URL.Query()
 
 	var collections []Collection
 
-	count, err := h.Db.Model(&collections).
+	query := h.Db.Model(&collections).
 		Column(
 			"collection.*",
+			"Tags",
 		).
-		Where("collection.user_id = ?", int(userId)).
-		Apply(orm.Pagination(r.URL.Query())).
+		Where(
+			`(collection.user_id = ?0 OR EXISTS(SELECT * FROM collection_collaborators
+			WHERE collection_id = collection.id AND user_id = ?0 AND status = ?1))`,
+			int64(userId), AcceptedInvitation,
+		)
+	if tag := queryValues.Get("tag"); tag != "" {
+		query = query.Where(
+			`EXISTS(SELECT * FROM collection_tags AS ct
+			JOIN tags ON tags.id = ct.tag_id
+			WHERE ct.collection_id = collection.id AND tags.title = ?)`,
+			strings.TrimSpace(strings.Title(tag)),
+		)
+	}
+	count, err := query.
+		Apply(orm.Pagination(queryValues)).
 		SelectAndCount()
 	if err != nil {
 		utils.RespondWithError(
@@ -72,11 +225,16 @@ func (h *Handler) GetAllCollections(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// UpdateCollectionEndpoint updates a new collection
+// UpdateCollectionEndpoint updates the name, tags, privacy and learning path
+// mode of a collection; only the owner can change the privacy and the mode
 func (h *Handler) UpdateCollectionEndPoint(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	var collection Collection
+	var collection struct {
+		Name         *string
+		Privacy      *string
+		LearningPath *bool
+	}
 
 	params := mux.Vars(r)
 
@@ -90,31 +248,74 @@ func (h *Handler) UpdateCollectionEndPoint(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	foundCollection := Collection{Id: collectionID}
-
 	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	if collection.Name != "" {
-		foundCollection.Name = collection.Name
-	} else {
+	// the name can be left out when other fields change
+	_, addsTags := context.GetOk(r, "tags")
+	_, removesTags := context.GetOk(r, "removed_tags")
+	ownerOnly := collection.Privacy != nil || collection.LearningPath != nil
+	if (collection.Name == nil && !ownerOnly && !addsTags && !removesTags) ||
+		(collection.Name != nil && strings.TrimSpace(*collection.Name) == "") {
 		utils.RespondWithError(w, http.StatusBadRequest, "Please enter valid collection name")
 		return
 	}
-
-	res, err := h.Db.Model(&foundCollection).Where("id = ? and user_id = ?", collectionID, userId).Column("name").Update()
-
-	if err == nil {
-		if res.RowsAffected() == 0 {
-			utils.RespondWithJsonError(w, http.StatusNotFound, "Collection not found")
+	if collection.Privacy != nil {
+		if err := utils.ValidateCollectionPrivacy(*collection.Privacy); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+	}
+
+	var foundCollection *Collection
+	var addedTagTitles, removedTagTitles []string
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		foundCollection, err = lockEditableCollection(
+			tx, collectionID, int64(userId), ownerOnly,
+		)
+		if err != nil {
+			return err
+		}
+		var columns []string
+		if collection.Name != nil {
+			foundCollection.Name = strings.TrimSpace(*collection.Name)
+			columns = append(columns, "name")
+		}
+		if collection.Privacy != nil {
+			foundCollection.Privacy = *collection.Privacy
+			if err := setShareToken(foundCollection); err != nil {
+				return err
+			}
+			columns = append(columns, "privacy", "share_token")
+		}
+		if collection.LearningPath != nil {
+			foundCollection.LearningPath = *collection.LearningPath
+			columns = append(columns, "learning_path")
+		}
+		if len(columns) > 0 {
+			_, err = tx.Model(foundCollection).WherePK().Column(columns...).Update()
+			if err != nil {
+				return err
+			}
+		}
+		addedTagTitles, removedTagTitles, err = tagCollection(tx, r, collectionID)
+		return err
+	})
+
+	if err == errCollectionNotFound || err == errCollectionForbidden {
+		respondWithCollectionError(w, err)
+	} else if err == nil {
 		payload := map[string]interface{}{
 			"updatedCollection": foundCollection,
+			"addedTags":         addedTagTitles,
+			"removedTags":       removedTagTitles,
 			"message":           "Collection Updated Successfully",
 		}
+		if foundCollection.UserId == int64(userId) && foundCollection.ShareToken != "" {
+			payload["shareToken"] = foundCollection.ShareToken
+		}
 		utils.RespondWithJson(w, http.StatusOK, payload)
 	} else {
 		fmt.Println(err.Error())
@@ -126,11 +327,15 @@ func (h *Handler) UpdateCollectionEndPoint(w http.ResponseWriter, r *http.Reques
 	return
 }
 
-// AddResourceToCollection - It allows users add a resource to a collection
+// AddResourceToCollection - It allows users add a resource they can access
+// to a collection they can edit, optionally in a section of a learning path
 func (h *Handler) AddResourceToCollection(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	var payload struct{ ResourceId int64 }
+	var payload struct {
+		ResourceId int64
+		Section    string
+	}
 	err := json.NewDecoder(r.Body).Decode(&payload)
 
 	if err != nil || payload.ResourceId == 0 {
@@ -139,41 +344,290 @@ func (h *Handler) AddResourceToCollection(w http.ResponseWriter, r *http.Request
 		)
 		return
 	}
+	if err := utils.ValidateSection(&payload.Section); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
-	query := `INSERT INTO resource_collections(resource_id, collection_id) VALUES ($1, $2)`
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var collection *Collection
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		// the collection stays locked so concurrent additions get distinct
+		// positions
+		var err error
+		collection, err = lockRouteCollection(tx, r, false)
+		if err != nil {
+			return err
+		}
+		visible, err := tx.Model(&Resource{}).
+			Where("resource.id = ?", payload.ResourceId).
+			Apply(visibleTo(int64(userId))).
+			Exists()
+		if err != nil {
+			return err
+		} else if !visible {
+			return pg.ErrNoRows
+		}
+		_, err = tx.Exec(
+			`INSERT INTO resource_collections
+				(resource_id, collection_id, position, section)
+			SELECT ?0, ?1, COALESCE(MAX(position), 0) + 1, NULLIF(?2, '')
+			FROM resource_collections
+			WHERE collection_id = ?1`,
+			payload.ResourceId, collection.Id, payload.Section,
+		)
+		return err
+	})
 
-	values := []interface{}{
-		payload.ResourceId,
-		collectionId,
+	if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23505" {
+		utils.RespondWithError(
+			w, http.StatusConflict,
+			"Resource already added to collection",
+		)
+	} else if err == errCollectionForbidden {
+		respondWithCollectionError(w, err)
+	} else if err == pg.ErrNoRows || err == errCollectionNotFound {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"Resource or Collection does not exist",
+		)
+	} else if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong!",
+		)
+	} else {
+		var resourceOwnerId int64
+		if err := h.Db.Model(&Resource{}).
+			Column("user_id").
+			Where("id = ?", payload.ResourceId).
+			Select(pg.Scan(&resourceOwnerId)); err == nil {
+			h.notify(Notification{
+				UserId:       resourceOwnerId,
+				ActorId:      int64(userId),
+				Type:         CollectionNotification,
+				ResourceId:   payload.ResourceId,
+				CollectionId: collection.Id,
+			})
+		}
+		h.notifyCollectionFollowers(collection, payload.ResourceId, int64(userId))
+		message := "resource added to collection"
+		key := "message"
+		utils.RespondWithSuccess(w, http.StatusOK, message, key)
+	}
+
+}
+
+// respondWithCollectionError respond to an error managing a collection
+func respondWithCollectionError(w http.ResponseWriter, err error) {
+	switch err {
+	case errCollectionNotFound, errNotInCollection:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errInvalidPosition:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errCollectionForbidden:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// GetCollection get a public collection, or a collection of the user or
+// shared with the user, its tags and its resources in order, leaving out the
+// resources the user cannot access
+func (h *Handler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	collection, role, err := collectionAccess(h.Db, collectionId, int64(userId), false)
+	if err == nil && !collectionViewable(collection, role) {
+		err = errCollectionNotFound
 	}
+	h.respondWithCollection(w, r, collection, role, err)
+}
 
-	stmt, err := h.Db.Prepare(query)
+// respondWithCollection respond with a collection the user can view, its
+// tags and the resources the user can access, or with err; the share token
+// is only sent to the owner
+func (h *Handler) respondWithCollection(
+	w http.ResponseWriter, r *http.Request, collection *Collection, role string, err error,
+) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	count := 0
+	if err == nil {
+		err = h.Db.Model(&collection.Tags).
+			Join("JOIN collection_tags AS ct ON ct.tag_id = tag.id").
+			Where("ct.collection_id = ?", collection.Id).
+			Order("tag.title ASC").
+			Select()
+	}
+	if err == nil {
+		count, err = h.Db.Model(&collection.Resources).
+			Column("resource.*", "Tags").
+			Join("JOIN resource_collections AS rc ON rc.resource_id = resource.id").
+			Where("rc.collection_id = ?", collection.Id).
+			Apply(visibleTo(int64(userId))).
+			Order("rc.position ASC").
+			Apply(orm.Pagination(r.URL.Query())).
+			SelectAndCount()
+	}
+	if err != nil {
+		respondWithCollectionError(w, err)
+		return
+	}
+	payload := map[string]interface{}{
+		"totalCount": count,
+		"collection": collection,
+		"role":       role,
+	}
+	if role == collectionOwner && collection.ShareToken != "" {
+		payload["shareToken"] = collection.ShareToken
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
 
+// DeleteCollection delete a collection of the user; its resources are kept.
+// Only the owner can delete a collection
+func (h *Handler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	var collection *Collection
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var err error
+		if collection, err = lockRouteCollection(tx, r, true); err != nil {
+			return err
+		}
+		_, err = tx.Model(collection).WherePK().Delete()
+		return err
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-	} else if _, err := stmt.Exec(values...); err != nil {
-		if err.(pg.Error).Field('C') == "23505" {
-			utils.RespondWithError(
-				w, http.StatusConflict,
-				"Resource already added to collection",
-			)
-		} else if err.(pg.Error).Field('C') == "23503" {
-			utils.RespondWithError(
-				w, http.StatusNotFound,
-				"Resource or Collection does not exist",
-			)
-		} else {
-			utils.RespondWithError(
-				w, http.StatusInternalServerError,
-				"Something went wrong!",
-			)
+		respondWithCollectionError(w, err)
+		return
+	}
+	event := auditEvent(r, "deleteCollection", "collection", collection.Id)
+	event.Before = map[string]interface{}{"name": collection.Name}
+	h.Audit.Record(event)
+	utils.RespondWithSuccess(w, http.StatusOK, "Collection deleted", "message")
+}
+
+// RemoveResourceFromCollection remove a resource from a collection the user
+// can edit; the resources after it move up
+func (h *Handler) RemoveResourceFromCollection(w http.ResponseWriter, r *http.Request) {
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		collection, err := lockRouteCollection(tx, r, false)
+		if err != nil {
+			return err
+		}
+		// the positions are compacted by a trigger, as when the resource
+		// itself is deleted
+		res, err := tx.Model(&ResourceCollection{}).
+			Where("collection_id = ? AND resource_id = ?", collection.Id, resourceId).
+			Delete()
+		if err == nil && res.RowsAffected() == 0 {
+			return errNotInCollection
 		}
+		return err
+	})
+	if err != nil {
+		respondWithCollectionError(w, err)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Resource removed from collection", "message",
+		)
+	}
+}
+
+// MoveResourceInCollection move a resource of a collection the user can
+// edit to another position; the resources in between shift to make room
+func (h *Handler) MoveResourceInCollection(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Position int }
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Position < 1 {
+		respondWithCollectionError(w, errInvalidPosition)
 		return
+	}
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		collection, err := lockRouteCollection(tx, r, false)
+		if err != nil {
+			return err
+		}
+		var from, count int
+		_, err = tx.QueryOne(
+			pg.Scan(&from, &count),
+			`SELECT position, (SELECT COUNT(*) FROM resource_collections
+				WHERE collection_id = ?0)
+			FROM resource_collections
+			WHERE collection_id = ?0 AND resource_id = ?1`,
+			collection.Id, resourceId,
+		)
+		if err == pg.ErrNoRows {
+			return errNotInCollection
+		} else if err != nil {
+			return err
+		}
+		to := payload.Position
+		if to > count {
+			to = count
+		}
+		_, err = tx.Exec(
+			`UPDATE resource_collections SET position = CASE
+				WHEN resource_id = ?1 THEN ?3
+				WHEN ?2 < ?3 THEN position - 1
+				ELSE position + 1
+			END
+			WHERE collection_id = ?0 AND position BETWEEN LEAST(?2, ?3) AND GREATEST(?2, ?3)`,
+			collection.Id, resourceId, from, to,
+		)
+		return err
+	})
+	if err != nil {
+		respondWithCollectionError(w, err)
 	} else {
-		message := "resource added to collection"
-		key := "message"
-		utils.RespondWithSuccess(w, http.StatusOK, message, key)
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Resource moved in collection", "message",
+		)
 	}
+}
 
+// UpdateSection put a resource of a collection the user can edit in a
+// section of the learning path, or take it out of its section when the
+// section is empty
+func (h *Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Section *string }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Section == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "A section is required")
+		return
+	}
+	if err := utils.ValidateSection(payload.Section); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		collection, err := lockRouteCollection(tx, r, false)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Model(&ResourceCollection{}).
+			Set("section = NULLIF(?, '')", *payload.Section).
+			Where("collection_id = ? AND resource_id = ?", collection.Id, resourceId).
+			Update()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotInCollection
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithCollectionError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Section updated", "message")
+	}
 }
@@ -4,18 +4,52 @@ import (
 	. "WeKnow_api/model"
 	utils "WeKnow_api/utilities"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-pg/pg"
 	"github.com/go-pg/pg/orm"
 	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
 
 	"net/http"
 )
 
-// AddComment add a comment to a resource
+var (
+	errCommentNotFound     = errors.New("Comment does not exist")
+	errNotCommentAuthor    = errors.New("You can only edit your own comments")
+	errCannotDeleteComment = errors.New("You cannot delete this comment")
+)
+
+// commentMaxDepth get how deep replies can be nested, from the
+// COMMENT_MAX_DEPTH env var
+func commentMaxDepth() int {
+	if depth, err := strconv.Atoi(os.Getenv("COMMENT_MAX_DEPTH")); err == nil {
+		return depth
+	}
+	return 5
+}
+
+// commentThread a depth first query of the comments under the comments
+// matching a condition, and their replies; ?0 is the user viewing them
+const commentThread = `WITH RECURSIVE thread AS (
+	SELECT c, ARRAY[c.id] AS path
+	FROM comments AS c
+	WHERE (%s) AND (c.hidden_at IS NULL OR c.user_id = ?0)
+	UNION ALL
+	SELECT c, thread.path || c.id
+	FROM comments AS c
+	JOIN thread ON c.parent_id = (thread.c).id
+	WHERE c.hidden_at IS NULL OR c.user_id = ?0
+)
+`
+
+// AddComment add a comment or a reply to a resource
 func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	var comment Comment
 	err := json.NewDecoder(r.Body).Decode(&comment)
@@ -28,7 +62,9 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	}
 	decodedClaims := context.Get(r, "decoded")
 	userId := decodedClaims.(jwt.MapClaims)["userId"].(float64)
-	comment.UserId, comment.Likes = int64(userId), 0
+	comment.UserId, comment.Likes, comment.Depth = int64(userId), 0, 0
+	comment.Reactions, comment.MyReactions = nil, nil
+	comment.EditedAt, comment.DeletedAt, comment.HiddenAt = nil, nil, nil
 	err = utils.ValidateNewComment(&comment)
 	if err != nil {
 		utils.RespondWithJsonError(
@@ -38,8 +74,73 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	if err := h.Db.Insert(&comment); err != nil {
-		if err.(pg.Error).Field('C') == "23503" {
+	if comment.ParentId != 0 {
+		parent := Comment{Id: comment.ParentId}
+		err := h.Db.Model(&parent).
+			Column("resource_id", "depth", "deleted_at").
+			WherePK().
+			Where("hidden_at IS NULL").
+			Select()
+		if err == pg.ErrNoRows || (err == nil && parent.ResourceId != comment.ResourceId) {
+			utils.RespondWithError(
+				w, http.StatusNotFound,
+				"The parent comment does not exist on this resource",
+			)
+			return
+		} else if err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wrong",
+			)
+			return
+		}
+		if parent.DeletedAt != nil {
+			utils.RespondWithError(
+				w, http.StatusBadRequest,
+				"You cannot reply to a deleted comment",
+			)
+			return
+		}
+		if parent.Depth >= commentMaxDepth() {
+			utils.RespondWithError(
+				w, http.StatusBadRequest,
+				"Replies cannot be nested any deeper",
+			)
+			return
+		}
+		comment.Depth = parent.Depth + 1
+	}
+	var resourceOwnerId int64
+	err = h.Db.Model(&Resource{}).
+		Column("user_id").
+		Where("id = ?", comment.ResourceId).
+		Select(pg.Scan(&resourceOwnerId))
+	if err == nil {
+		if blocked, err := h.isBlocked(comment.UserId, resourceOwnerId); err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wrong",
+			)
+			return
+		} else if blocked {
+			utils.RespondWithError(
+				w, http.StatusForbidden,
+				"You cannot comment on this resource",
+			)
+			return
+		}
+	}
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		if err := tx.Insert(&comment); err != nil {
+			return err
+		}
+		comment.Mentions, err = recordMentions(
+			tx, comment.UserId, comment.ResourceId, comment.Id, comment.Text,
+		)
+		return err
+	})
+	if err != nil {
+		if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23503" {
 			errorMsg := fmt.Sprintf(
 				"Resource with id %d does not exist",
 				comment.ResourceId,
@@ -55,6 +156,17 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 			)
 		}
 	} else {
+		h.notify(Notification{
+			UserId:     resourceOwnerId,
+			ActorId:    comment.UserId,
+			Type:       CommentNotification,
+			ResourceId: comment.ResourceId,
+			CommentId:  comment.Id,
+		})
+		// the owner of the resource is already notified of the comment
+		h.notifyMentions(comment.UserId, comment.Mentions, map[int64]bool{
+			resourceOwnerId: true,
+		})
 		payload := map[string]interface{}{
 			"comment": comment,
 			"message": "Comment added to resource",
@@ -64,7 +176,10 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// GetComments get comments filtered by resource
+// GetComments get comments filtered by resource, as a flat list, a tree of
+// replies or a depth first thread; tree and thread start at the top level
+// comments, or at the replies of parentId, and sort orders flat lists and
+// the top level of trees
 func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	if err := utils.ValidateQueryParams(queryValues); err != nil {
@@ -96,9 +211,316 @@ func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	var comments []Comment
-	count, err := h.Db.Model(&comments).
-		Where(condition, values...).
+	var parentId interface{}
+	if value := queryValues.Get("parentId"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			utils.RespondWithError(
+				w, http.StatusBadRequest,
+				"parentId must be a valid comment Id",
+			)
+			return
+		}
+		parentId = id
+	}
+	order := "id ASC"
+	switch queryValues.Get("sort") {
+	case "", "oldest":
+	case "newest":
+		order = "id DESC"
+	case "likes":
+		order = "likes DESC, id ASC"
+	default:
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"sort must be one of 'oldest', 'newest' or 'likes'",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var comments []*Comment
+	var count int
+	var err error
+	switch queryValues.Get("view") {
+	case "", "flat":
+		query := h.Db.Model(&comments).
+			Where(condition, values...).
+			// hidden comments are only visible to their author
+			Where("hidden_at IS NULL OR user_id = ?", int64(userId))
+		if parentId != nil {
+			query = query.Where("parent_id = ?", parentId)
+		}
+		count, err = query.
+			OrderExpr(order).
+			Apply(orm.Pagination(r.URL.Query())).
+			SelectAndCount()
+	case "tree":
+		comments, count, err = h.getCommentTree(r, values[0], parentId, int64(userId), order)
+	case "thread":
+		comments, count, err = h.getCommentThread(r, values[0], parentId, int64(userId))
+	default:
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"view must be one of 'flat', 'tree' or 'thread'",
+		)
+		return
+	}
+	if err == nil {
+		err = h.setMyReactions(int64(userId), withReplies(comments))
+	}
+	if err == nil {
+		err = h.setCommentMentions(withReplies(comments))
+	}
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"comments":   comments,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// withReplies get comments and all their nested replies
+func withReplies(comments []*Comment) []*Comment {
+	all := append([]*Comment{}, comments...)
+	for _, comment := range comments {
+		all = append(all, withReplies(comment.Replies)...)
+	}
+	return all
+}
+
+// getCommentTree get a page of comments of a resource in order, with their
+// replies nested in them
+func (h *Handler) getCommentTree(
+	r *http.Request, resourceId, parentId interface{}, userId int64, order string,
+) ([]*Comment, int, error) {
+	roots := []*Comment{}
+	count, err := h.Db.Model(&roots).
+		Where("resource_id = ?", resourceId).
+		Where("parent_id IS NOT DISTINCT FROM ?", parentId).
+		Where("hidden_at IS NULL OR user_id = ?", userId).
+		OrderExpr(order).
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil || len(roots) == 0 {
+		return roots, count, err
+	}
+	byId := map[int64]*Comment{}
+	var rootIds []int64
+	for _, root := range roots {
+		byId[root.Id] = root
+		rootIds = append(rootIds, root.Id)
+	}
+	var replies []*Comment
+	_, err = h.Db.Query(&replies,
+		fmt.Sprintf(commentThread, "c.parent_id IN (?1)")+
+			"SELECT (thread.c).* FROM thread ORDER BY path",
+		userId, pg.In(rootIds),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	// parents come before their replies in a depth first thread
+	for _, reply := range replies {
+		byId[reply.Id] = reply
+		parent := byId[reply.ParentId]
+		parent.Replies = append(parent.Replies, reply)
+	}
+	return roots, count, nil
+}
+
+// getCommentThread get a page of the comments of a resource flattened into
+// a depth first thread
+func (h *Handler) getCommentThread(
+	r *http.Request, resourceId, parentId interface{}, userId int64,
+) ([]*Comment, int, error) {
+	query := fmt.Sprintf(
+		commentThread,
+		"c.resource_id = ?1 AND c.parent_id IS NOT DISTINCT FROM ?2",
+	)
+	var count int
+	_, err := h.Db.QueryOne(pg.Scan(&count),
+		query+"SELECT count(*) FROM thread",
+		userId, resourceId, parentId,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	pager := orm.NewPager(r.URL.Query())
+	comments := []*Comment{}
+	_, err = h.Db.Query(&comments,
+		query+"SELECT (thread.c).* FROM thread ORDER BY path LIMIT ?3 OFFSET ?4",
+		userId, resourceId, parentId, pager.GetLimit(), pager.GetOffset(),
+	)
+	return comments, count, err
+}
+
+// UpdateComment change the text of a comment of the user, keeping the
+// previous text in its edit history
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Text string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	payload.Text = strings.TrimSpace(payload.Text)
+	if payload.Text == "" {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"comment Text is required, it cannot be empty",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	comment := Comment{Id: commentId}
+	var mentioned map[int64]bool
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&comment).
+			WherePK().
+			Where("deleted_at IS NULL").
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errCommentNotFound
+		} else if err != nil {
+			return err
+		}
+		if comment.UserId != int64(userId) {
+			return errNotCommentAuthor
+		}
+		if comment.Text == payload.Text {
+			return h.setCommentMentions([]*Comment{&comment})
+		}
+		_, err = tx.Model(&CommentEdit{
+			CommentId: comment.Id,
+			Text:      comment.Text,
+		}).Insert()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		comment.Text, comment.EditedAt = payload.Text, &now
+		_, err = tx.Model(&comment).
+			Column("text", "edited_at", "updated_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		comment.Mentions, mentioned, err = replaceMentions(
+			tx, comment.UserId, comment.ResourceId, comment.Id, comment.Text,
+		)
+		return err
+	})
+	switch err {
+	case nil:
+		h.notifyMentions(comment.UserId, comment.Mentions, mentioned)
+		payload := map[string]interface{}{
+			"comment": comment,
+			"message": "Comment updated",
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	case errCommentNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errNotCommentAuthor:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// softDeleteComment blank a comment so it stays as a placeholder in its
+// thread
+func softDeleteComment(tx *pg.Tx, commentId int64) error {
+	_, err := tx.Model(&Comment{}).
+		Set("text = '', deleted_at = now(), updated_at = now()").
+		Where("id = ?", commentId).
+		Update()
+	if err != nil {
+		return err
+	}
+	// the edit history and mentions would keep the text of the deleted
+	// comment
+	_, err = tx.Model(&CommentEdit{}).
+		Where("comment_id = ?", commentId).
+		Delete()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Model(&Mention{}).
+		Where("comment_id = ?", commentId).
+		Delete()
+	return err
+}
+
+// DeleteComment delete a comment of the user or on a resource of the user;
+// the comment stays as a placeholder so its replies keep their thread
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var comment Comment
+		err := tx.Model(&comment).
+			Column("comment.id", "comment.user_id", "Resource.user_id").
+			Where("comment.id = ?", commentId).
+			Where("comment.deleted_at IS NULL").
+			For("UPDATE OF comment").
+			Select()
+		if err == pg.ErrNoRows {
+			return errCommentNotFound
+		} else if err != nil {
+			return err
+		}
+		if comment.UserId != int64(userId) && comment.Resource.UserId != int64(userId) {
+			return errCannotDeleteComment
+		}
+		return softDeleteComment(tx, comment.Id)
+	})
+	switch err {
+	case nil:
+		utils.RespondWithSuccess(w, http.StatusOK, "Comment deleted", "message")
+	case errCommentNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errCannotDeleteComment:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// GetCommentEdits get the previous texts of a comment, newest first
+func (h *Handler) GetCommentEdits(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	exists, err := h.Db.Model(&Comment{}).
+		Where("id = ? AND deleted_at IS NULL", commentId).
+		Where("hidden_at IS NULL OR user_id = ?", int64(userId)).
+		Exists()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	} else if !exists {
+		utils.RespondWithError(w, http.StatusNotFound, errCommentNotFound.Error())
+		return
+	}
+	var edits []CommentEdit
+	count, err := h.Db.Model(&edits).
+		Where("comment_id = ?", commentId).
+		Order("created_at DESC", "id DESC").
 		Apply(orm.Pagination(r.URL.Query())).
 		SelectAndCount()
 	if err != nil {
@@ -109,7 +531,7 @@ func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 	} else {
 		payload := map[string]interface{}{
 			"totalCount": count,
-			"comments":   comments,
+			"edits":      edits,
 		}
 		utils.RespondWithJson(w, http.StatusOK, payload)
 	}
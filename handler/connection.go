@@ -0,0 +1,343 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// isBlocked check if either of two users blocked the other
+func (h *Handler) isBlocked(userId, otherUserId int64) (bool, error) {
+	return h.Db.Model(&Block{}).
+		Where(
+			`(blocker_id = ?0 AND blocked_id = ?1) OR
+			(blocker_id = ?1 AND blocked_id = ?0)`,
+			userId, otherUserId,
+		).
+		Exists()
+}
+
+// targetUserId decode the id of the user targeted by a block or mute
+func targetUserId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	defer r.Body.Close()
+	var payload struct{ UserId int64 }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.UserId == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid userId is required",
+		)
+		return 0, false
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	if payload.UserId == int64(userId) {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"You cannot perform this action on yourself",
+		)
+		return 0, false
+	}
+	return payload.UserId, true
+}
+
+// respondWithRelationError respond to an error creating a block or mute
+func respondWithRelationError(w http.ResponseWriter, err error) {
+	if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23503" {
+		utils.RespondWithError(w, http.StatusNotFound, "User does not exist")
+	} else {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// Unfollow remove the connection from the user to another user
+func (h *Handler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	recipientId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if recipientId == 0 {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"Invalid user Id in request",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	res, err := h.Db.Model(&Connection{}).
+		Where("initiator_id = ? AND recipient_id = ?", int64(userId), recipientId).
+		Delete()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else if res.RowsAffected() == 0 {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"You are not connected with this user",
+		)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Unfollowed user successfully", "message",
+		)
+	}
+}
+
+// BlockUser block a user and remove the connections between both users
+func (h *Handler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	blockedId, ok := targetUserId(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	block := Block{BlockerId: int64(userId), BlockedId: blockedId}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model(&block).OnConflict("DO NOTHING").Insert(); err != nil {
+			return err
+		}
+		_, err := tx.Model(&Connection{}).
+			Where(
+				`(initiator_id = ?0 AND recipient_id = ?1) OR
+				(initiator_id = ?1 AND recipient_id = ?0)`,
+				block.BlockerId, block.BlockedId,
+			).
+			Delete()
+		return err
+	})
+	if err != nil {
+		respondWithRelationError(w, err)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Blocked user successfully", "message",
+		)
+	}
+}
+
+// UnblockUser remove a block on a user
+func (h *Handler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	blockedId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	res, err := h.Db.Model(&Block{}).
+		Where("blocker_id = ? AND blocked_id = ?", int64(userId), blockedId).
+		Delete()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else if res.RowsAffected() == 0 {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"You have not blocked this user",
+		)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Unblocked user successfully", "message",
+		)
+	}
+}
+
+// GetBlockedUsers get the users blocked by the user
+func (h *Handler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var blocks []Block
+	count, err := h.Db.Model(&blocks).
+		Column(
+			"block.blocked_id",
+			"block.created_at",
+			"Blocked.id",
+			"Blocked.username",
+		).
+		Where("blocker_id = ?", int64(userId)).
+		Order("block.created_at DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"blocked":    blocks,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// MuteUser hide the content of a user from the feed of the user
+func (h *Handler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	mutedId, ok := targetUserId(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	mute := Mute{MuterId: int64(userId), MutedId: mutedId}
+	if _, err := h.Db.Model(&mute).OnConflict("DO NOTHING").Insert(); err != nil {
+		respondWithRelationError(w, err)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Muted user successfully", "message",
+		)
+	}
+}
+
+// UnmuteUser show the content of a muted user in the feed again
+func (h *Handler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	mutedId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	res, err := h.Db.Model(&Mute{}).
+		Where("muter_id = ? AND muted_id = ?", int64(userId), mutedId).
+		Delete()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else if res.RowsAffected() == 0 {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"You have not muted this user",
+		)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Unmuted user successfully", "message",
+		)
+	}
+}
+
+// GetMutedUsers get the users muted by the user
+func (h *Handler) GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var mutes []Mute
+	count, err := h.Db.Model(&mutes).
+		Column(
+			"mute.muted_id",
+			"mute.created_at",
+			"Muted.id",
+			"Muted.username",
+		).
+		Where("muter_id = ?", int64(userId)).
+		Order("mute.created_at DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"muted":      mutes,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// getFollowRequests get pending connections of the user matching condition
+func (h *Handler) getFollowRequests(
+	w http.ResponseWriter, r *http.Request, condition, user string,
+) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var connections []Connection
+	count, err := h.Db.Model(&connections).
+		Column(
+			"connection.id",
+			"initiator_id",
+			"recipient_id",
+			"status",
+			"connection.created_at",
+			user+".id",
+			user+".username",
+		).
+		Where(condition, int64(userId)).
+		Where("status = ?", PendingConnection).
+		Order("connection.created_at DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"requests":   connections,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetIncomingFollowRequests get pending requests to follow the user
+func (h *Handler) GetIncomingFollowRequests(w http.ResponseWriter, r *http.Request) {
+	h.getFollowRequests(w, r, "recipient_id = ?", "Initiator")
+}
+
+// GetOutgoingFollowRequests get pending requests of the user to follow others
+func (h *Handler) GetOutgoingFollowRequests(w http.ResponseWriter, r *http.Request) {
+	h.getFollowRequests(w, r, "initiator_id = ?", "Recipient")
+}
+
+// RespondToFollowRequest accept or reject a request to follow the user
+func (h *Handler) RespondToFollowRequest(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	initiatorId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	var payload struct{ Accept *bool }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Accept == nil {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"Accept must be true or false",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	query := h.Db.Model(&Connection{}).
+		Where(
+			"initiator_id = ? AND recipient_id = ? AND status = ?",
+			initiatorId, int64(userId), PendingConnection,
+		)
+	var res orm.Result
+	if *payload.Accept {
+		res, err = query.Set("status = ?", AcceptedConnection).Update()
+	} else {
+		res, err = query.Delete()
+	}
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	if res.RowsAffected() == 0 {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"There is no pending request from this user",
+		)
+		return
+	}
+	if *payload.Accept {
+		h.notify(Notification{
+			UserId:  int64(userId),
+			ActorId: initiatorId,
+			Type:    FollowerNotification,
+		})
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Follow request accepted", "message",
+		)
+	} else {
+		utils.RespondWithSuccess(
+			w, http.StatusOK, "Follow request rejected", "message",
+		)
+	}
+}
@@ -0,0 +1,303 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errAlreadyFollowingCollection = errors.New("You already follow this collection")
+	errNotFollowingCollection     = errors.New("You do not follow this collection")
+	errCannotFollowOwnCollection  = errors.New("You cannot follow your own collection")
+	errCollectionNameTaken        = errors.New("A collection exists with this name")
+)
+
+// respondWithDiscoveryError respond to an error following or forking a
+// collection
+func respondWithDiscoveryError(w http.ResponseWriter, err error) {
+	switch err {
+	case errNotFollowingCollection:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errCannotFollowOwnCollection:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errAlreadyFollowingCollection, errCollectionNameTaken:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		respondWithCollectionError(w, err)
+	}
+}
+
+// targetCollectionId decode the collectionId in the request body
+func targetCollectionId(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	defer r.Body.Close()
+	var payload struct {
+		CollectionId int64
+		Name         string
+	}
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.CollectionId == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid collectionId is required",
+		)
+		return 0, "", false
+	}
+	return payload.CollectionId, payload.Name, true
+}
+
+// GetPublicCollections browse public collections, newest first, optionally
+// only those of a user or with a tag
+func (h *Handler) GetPublicCollections(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	var collections []Collection
+	query := h.Db.Model(&collections).
+		Column("collection.*", "Tags").
+		Where("collection.privacy = ?", PublicCollection).
+		Where("collection.hidden_at IS NULL")
+	if owner := queryValues.Get("userId"); owner != "" {
+		ownerId, _ := strconv.ParseInt(owner, 10, 64)
+		query = query.Where("collection.user_id = ?", ownerId)
+	}
+	if tag := queryValues.Get("tag"); tag != "" {
+		query = query.Where(
+			`EXISTS(SELECT * FROM collection_tags AS ct
+			JOIN tags ON tags.id = ct.tag_id
+			WHERE ct.collection_id = collection.id AND tags.title = ?)`,
+			strings.TrimSpace(strings.Title(tag)),
+		)
+	}
+	count, err := query.
+		Order("collection.created_at DESC", "collection.id DESC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":  count,
+			"collections": collections,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetSharedCollection get an unlisted or public collection through its
+// share token
+func (h *Handler) GetSharedCollection(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var collectionId int64
+	err := h.Db.Model(&Collection{}).
+		Column("id").
+		Where("share_token = ?", mux.Vars(r)["shareToken"]).
+		Where("privacy != ?", PrivateCollection).
+		Select(pg.Scan(&collectionId))
+	if err == pg.ErrNoRows {
+		err = errCollectionNotFound
+	}
+	var collection *Collection
+	role := ""
+	if err == nil {
+		collection, role, err = collectionAccess(h.Db, collectionId, int64(userId), false)
+	}
+	if err == nil && role == "" && collection.HiddenAt != nil {
+		err = errCollectionNotFound
+	}
+	h.respondWithCollection(w, r, collection, role, err)
+}
+
+// FollowCollection follow a collection the user can view, to be notified
+// when resources are added to it
+func (h *Handler) FollowCollection(w http.ResponseWriter, r *http.Request) {
+	collectionId, _, ok := targetCollectionId(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var collection Collection
+	err := h.Db.Model(&collection).
+		Column("collection.user_id").
+		Where("collection.id = ?1 AND "+collectionVisibility("collection"),
+			int64(userId), collectionId,
+		).
+		Select()
+	if err == pg.ErrNoRows {
+		err = errCollectionNotFound
+	} else if err == nil && collection.UserId == int64(userId) {
+		err = errCannotFollowOwnCollection
+	}
+	if err == nil {
+		var res orm.Result
+		res, err = h.Db.Model(&CollectionFollower{
+			CollectionId: collectionId,
+			UserId:       int64(userId),
+		}).OnConflict("DO NOTHING").Insert()
+		if err == nil && res.RowsAffected() == 0 {
+			err = errAlreadyFollowingCollection
+		}
+	}
+	if err != nil {
+		respondWithDiscoveryError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Collection followed", "message")
+	}
+}
+
+// UnfollowCollection stop following a collection
+func (h *Handler) UnfollowCollection(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	res, err := h.Db.Model(&CollectionFollower{}).
+		Where("collection_id = ? AND user_id = ?", collectionId, int64(userId)).
+		Delete()
+	if err == nil && res.RowsAffected() == 0 {
+		err = errNotFollowingCollection
+	}
+	if err != nil {
+		respondWithDiscoveryError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Collection unfollowed", "message")
+	}
+}
+
+// GetFollowedCollections get the collections the user follows and can
+// still view, latest followed first
+func (h *Handler) GetFollowedCollections(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var collections []Collection
+	count, err := h.Db.Model(&collections).
+		Column("collection.*", "Tags").
+		Join("JOIN collection_followers AS cf ON cf.collection_id = collection.id").
+		Where("cf.user_id = ?", int64(userId)).
+		Where(collectionVisibility("collection"), int64(userId)).
+		Order("cf.created_at DESC", "collection.id DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":  count,
+			"collections": collections,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// notifyCollectionFollowers notify the followers of a public collection
+// who can access a resource added to it
+func (h *Handler) notifyCollectionFollowers(
+	collection *Collection, resourceId, actorId int64,
+) {
+	if collection.Privacy != PublicCollection || collection.HiddenAt != nil {
+		return
+	}
+	var followers []CollectionFollower
+	err := h.Db.Model(&followers).
+		Column("collection_follower.user_id").
+		Join("JOIN resources AS resource ON resource.id = ?", resourceId).
+		Where("collection_follower.collection_id = ?", collection.Id).
+		Where(resourceVisibilityFor("resource", "collection_follower.user_id")).
+		Select()
+	if err != nil {
+		log.Printf("Could not select the followers of %v: %v", collection, err)
+		return
+	}
+	for _, follower := range followers {
+		h.notify(Notification{
+			UserId:       follower.UserId,
+			ActorId:      actorId,
+			Type:         FollowedCollectionNotification,
+			ResourceId:   resourceId,
+			CollectionId: collection.Id,
+		})
+	}
+}
+
+// ForkCollection copy a public collection into a new private collection of
+// the user, with its tags, its learning path mode and the resources the user
+// can access
+func (h *Handler) ForkCollection(w http.ResponseWriter, r *http.Request) {
+	sourceId, name, ok := targetCollectionId(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	source := Collection{Id: sourceId}
+	err := h.Db.Model(&source).
+		Column("collection.name", "collection.learning_path").
+		WherePK().
+		Where("collection.privacy = ?", PublicCollection).
+		Where("collection.hidden_at IS NULL").
+		Select()
+	if err == pg.ErrNoRows {
+		respondWithDiscoveryError(w, errCollectionNotFound)
+		return
+	} else if err != nil {
+		respondWithDiscoveryError(w, err)
+		return
+	}
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+	fork := Collection{
+		Name:         name,
+		UserId:       int64(userId),
+		LearningPath: source.LearningPath,
+		ForkedFromId: sourceId,
+	}
+	if err := utils.ValidateNewCollection(&fork); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Insert(&fork)
+		if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23505" {
+			return errCollectionNameTaken
+		} else if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO resource_collections
+			(resource_id, collection_id, position, section)
+		SELECT rc.resource_id, ?1, row_number() OVER (ORDER BY rc.position),
+			rc.section
+		FROM resource_collections AS rc
+		JOIN resources AS resource ON resource.id = rc.resource_id
+		WHERE rc.collection_id = ?2 AND `+resourceVisibility("resource"),
+			int64(userId), fork.Id, sourceId,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO collection_tags (tag_id, collection_id)
+		SELECT tag_id, ?0 FROM collection_tags WHERE collection_id = ?1`,
+			fork.Id, sourceId,
+		)
+		return err
+	})
+	if err != nil {
+		respondWithDiscoveryError(w, err)
+		return
+	}
+	payload := map[string]interface{}{
+		"message":    "Collection forked",
+		"collection": fork,
+	}
+	utils.RespondWithJson(w, http.StatusCreated, payload)
+}
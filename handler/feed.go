@@ -0,0 +1,146 @@
+package handler
+
+import (
+	utils "WeKnow_api/utilities"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+)
+
+// FeedItem an activity of a followed user
+type FeedItem struct {
+	Type          string
+	ItemId        int64
+	UserId        int64
+	Username      string
+	ResourceId    int64
+	ResourceTitle string
+	Text          string `json:",omitempty"`
+	CreatedAt     time.Time
+}
+
+// feedCursor position of the last item of a feed page
+type feedCursor struct {
+	CreatedAt time.Time
+	Type      string
+	ItemId    int64
+	UserId    int64
+}
+
+func (c feedCursor) encode() string {
+	raw := fmt.Sprintf(
+		"%s|%s|%d|%d",
+		c.CreatedAt.Format(time.RFC3339Nano), c.Type, c.ItemId, c.UserId,
+	)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFeedCursor(value string) (feedCursor, error) {
+	var cursor feedCursor
+	invalidCursorErr := errors.New("Invalid feed cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, invalidCursorErr
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 {
+		return cursor, invalidCursorErr
+	}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return cursor, invalidCursorErr
+	}
+	cursor.Type = parts[1]
+	if cursor.ItemId, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return cursor, invalidCursorErr
+	}
+	if cursor.UserId, err = strconv.ParseInt(parts[3], 10, 64); err != nil {
+		return cursor, invalidCursorErr
+	}
+	return cursor, nil
+}
+
+// GetFeed get resources, recommendations and comments of followed users
+func (h *Handler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	limit := orm.NewPager(queryValues).GetLimit()
+	if limit < 1 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit in request")
+		return
+	}
+
+	followed := `SELECT recipient_id FROM connections WHERE initiator_id = ?0
+		AND status = 'accepted'
+		AND recipient_id NOT IN (SELECT muted_id FROM mutes WHERE muter_id = ?0)`
+	query := fmt.Sprintf(`SELECT feed.*, users.username FROM (
+		SELECT 'resource' AS type, r.id AS item_id, r.user_id,
+			r.id AS resource_id, r.title AS resource_title,
+			NULL::text AS text, r.created_at
+		FROM resources AS r
+		WHERE r.user_id IN (%[1]s) AND %[2]s
+		UNION ALL
+		SELECT 'recommendation', rec.resource_id, rec.user_id,
+			r.id, r.title, NULL::text, rec.created_at
+		FROM recommendations AS rec
+		JOIN resources AS r ON r.id = rec.resource_id
+		WHERE rec.user_id IN (%[1]s) AND %[2]s
+		UNION ALL
+		SELECT 'comment', c.id, c.user_id, r.id, r.title, c.text, c.created_at
+		FROM comments AS c
+		JOIN resources AS r ON r.id = c.resource_id
+		WHERE c.user_id IN (%[1]s) AND c.hidden_at IS NULL
+			AND c.deleted_at IS NULL AND %[2]s
+	) AS feed
+	JOIN users ON users.id = feed.user_id`,
+		followed, resourceVisibility("r"),
+	)
+	values := []interface{}{int64(userId)}
+
+	if value := queryValues.Get("cursor"); value != "" {
+		cursor, err := decodeFeedCursor(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query += `
+	WHERE (feed.created_at, feed.type, feed.item_id, feed.user_id) <
+		(?1, ?2, ?3, ?4)`
+		values = append(
+			values, cursor.CreatedAt, cursor.Type, cursor.ItemId, cursor.UserId,
+		)
+	}
+	query += fmt.Sprintf(`
+	ORDER BY feed.created_at DESC, feed.type DESC,
+		feed.item_id DESC, feed.user_id DESC
+	LIMIT %d`, limit)
+
+	var feed []FeedItem
+	if _, err := h.Db.Query(&feed, query, values...); err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+
+	var nextCursor string
+	if len(feed) == limit {
+		last := feed[len(feed)-1]
+		nextCursor = feedCursor{
+			last.CreatedAt, last.Type, last.ItemId, last.UserId,
+		}.encode()
+	}
+	payload := map[string]interface{}{
+		"feed":       feed,
+		"nextCursor": nextCursor,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
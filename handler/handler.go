@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"WeKnow_api/services"
 	"encoding/json"
 	"net/http"
 
@@ -9,7 +10,11 @@ import (
 
 // Handler type Handler
 type Handler struct {
-	Db *pg.DB
+	Db     *pg.DB
+	Views  *services.ViewCounter
+	Hub    *services.Hub
+	Mailer services.Mailer
+	Audit  *services.AuditRecorder
 }
 
 // HomeHandler handle GET request to the root endpoint
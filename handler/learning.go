@@ -0,0 +1,415 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errNotLearningPath   = errors.New("This collection is not a learning path")
+	errAlreadyEnrolled   = errors.New("You are already enrolled in this learning path")
+	errNotEnrolled       = errors.New("You are not enrolled in this learning path")
+	errNotLearningOwner  = errors.New("Only the owner can see the stats of this learning path")
+	errInaccessibleStep  = errors.New("This step is not part of the learning path")
+	errInvalidCompletion = errors.New("Completed must be true or false")
+)
+
+// LearningPathStep a resource of a learning path, and when the user
+// completed it
+type LearningPathStep struct {
+	ResourceId  int64
+	Position    int
+	Section     string `json:",omitempty"`
+	Title       string
+	Type        string
+	Link        string
+	CompletedAt *time.Time `json:",omitempty"`
+}
+
+// LearningPathStepStats a step of a learning path and the number of enrolled
+// users who completed it
+type LearningPathStepStats struct {
+	LearningPathStep
+	CompletedCount int
+}
+
+// EnrollmentProgress a learning path the user is enrolled in and the
+// progress of the user
+type EnrollmentProgress struct {
+	Id             int64
+	Name           string
+	EnrolledAt     time.Time
+	TotalSteps     int
+	CompletedSteps int
+	Progress       int
+}
+
+// progressPercent the whole percentage of steps completed, 100 only once
+// every step is
+func progressPercent(completed, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return completed * 100 / total
+}
+
+// respondWithLearningError respond to an error following a learning path
+func respondWithLearningError(w http.ResponseWriter, err error) {
+	switch err {
+	case errNotEnrolled, errInaccessibleStep:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errNotLearningPath, errInvalidCompletion:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errNotLearningOwner:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errAlreadyEnrolled:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		respondWithCollectionError(w, err)
+	}
+}
+
+// viewLearningPath get a learning path the user can view, and the role of
+// the user on it
+func viewLearningPath(db orm.DB, collectionId, userId int64) (*Collection, string, error) {
+	collection, role, err := collectionAccess(db, collectionId, userId, false)
+	if err == nil && !collectionViewable(collection, role) {
+		err = errCollectionNotFound
+	} else if err == nil && !collection.LearningPath {
+		err = errNotLearningPath
+	}
+	return collection, role, err
+}
+
+// checkEnrollment check that the user is enrolled in a learning path the
+// user can still view
+func checkEnrollment(db orm.DB, collectionId, userId int64) error {
+	if _, _, err := viewLearningPath(db, collectionId, userId); err != nil {
+		return err
+	}
+	enrolled, err := db.Model(&Enrollment{}).
+		Where("collection_id = ? AND user_id = ?", collectionId, userId).
+		Exists()
+	if err == nil && !enrolled {
+		err = errNotEnrolled
+	}
+	return err
+}
+
+// learningPathSteps get the steps of a learning path the user can access,
+// in order, and when the user completed them
+func learningPathSteps(db orm.DB, collectionId, userId int64) ([]*LearningPathStep, error) {
+	var steps []*LearningPathStep
+	_, err := db.Query(&steps, fmt.Sprintf(`SELECT rc.resource_id, rc.position,
+		rc.section, resource.title, resource.type, resource.link,
+		sc.created_at AS completed_at
+	FROM resource_collections AS rc
+	JOIN resources AS resource ON resource.id = rc.resource_id
+	LEFT JOIN step_completions AS sc ON sc.collection_id = rc.collection_id
+		AND sc.resource_id = rc.resource_id AND sc.user_id = ?0
+	WHERE rc.collection_id = ?1 AND %s
+	ORDER BY rc.position ASC`, resourceVisibility("resource")),
+		userId, collectionId,
+	)
+	return steps, err
+}
+
+// nextStep get the step to continue with: the first step left after the
+// step completed last, or else the first step left; nil once every step is
+// completed
+func nextStep(steps []*LearningPathStep) *LearningPathStep {
+	start := 0
+	var lastCompletedAt time.Time
+	for i, step := range steps {
+		if step.CompletedAt != nil && step.CompletedAt.After(lastCompletedAt) {
+			start, lastCompletedAt = i+1, *step.CompletedAt
+		}
+	}
+	for i := range steps {
+		if step := steps[(start+i)%len(steps)]; step.CompletedAt == nil {
+			return step
+		}
+	}
+	return nil
+}
+
+// learningProgress get the progress of the user on a learning path
+func learningProgress(db orm.DB, collectionId, userId int64) (map[string]interface{}, error) {
+	steps, err := learningPathSteps(db, collectionId, userId)
+	if err != nil {
+		return nil, err
+	}
+	completed := 0
+	for _, step := range steps {
+		if step.CompletedAt != nil {
+			completed++
+		}
+	}
+	return map[string]interface{}{
+		"totalSteps":     len(steps),
+		"completedSteps": completed,
+		"progress":       progressPercent(completed, len(steps)),
+		"nextStep":       nextStep(steps),
+		"steps":          steps,
+	}, nil
+}
+
+// EnrollInLearningPath enroll the user in a learning path the user can view
+func (h *Handler) EnrollInLearningPath(w http.ResponseWriter, r *http.Request) {
+	collectionId, _, ok := targetCollectionId(w, r)
+	if !ok {
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	_, _, err := viewLearningPath(h.Db, collectionId, int64(userId))
+	if err == nil {
+		var res orm.Result
+		res, err = h.Db.Model(&Enrollment{
+			CollectionId: collectionId,
+			UserId:       int64(userId),
+		}).OnConflict("DO NOTHING").Insert()
+		if err == nil && res.RowsAffected() == 0 {
+			err = errAlreadyEnrolled
+		}
+	}
+	if err != nil {
+		respondWithLearningError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Enrolled in learning path", "message")
+	}
+}
+
+// LeaveLearningPath end the enrollment of the user in a learning path and
+// forget the steps the user completed
+func (h *Handler) LeaveLearningPath(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		res, err := tx.Model(&Enrollment{}).
+			Where("collection_id = ? AND user_id = ?", collectionId, int64(userId)).
+			Delete()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotEnrolled
+		}
+		_, err = tx.Model(&StepCompletion{}).
+			Where("collection_id = ? AND user_id = ?", collectionId, int64(userId)).
+			Delete()
+		return err
+	})
+	if err != nil {
+		respondWithLearningError(w, err)
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Left learning path", "message")
+	}
+}
+
+// CompleteStep mark a step of a learning path the user is enrolled in as
+// completed or not, and respond with the progress of the user
+func (h *Handler) CompleteStep(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Completed *bool }
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Completed == nil {
+		respondWithLearningError(w, errInvalidCompletion)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	completion := StepCompletion{
+		CollectionId: collectionId,
+		ResourceId:   resourceId,
+		UserId:       int64(userId),
+	}
+
+	var progress map[string]interface{}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		if err := checkEnrollment(tx, collectionId, int64(userId)); err != nil {
+			return err
+		}
+		accessible, err := tx.Model(&Resource{}).
+			Join("JOIN resource_collections AS rc ON rc.resource_id = resource.id").
+			Where("rc.collection_id = ? AND rc.resource_id = ?", collectionId, resourceId).
+			Apply(visibleTo(int64(userId))).
+			Exists()
+		if err != nil {
+			return err
+		} else if !accessible {
+			return errInaccessibleStep
+		}
+		if *payload.Completed {
+			_, err = tx.Model(&completion).OnConflict("DO NOTHING").Insert()
+		} else {
+			_, err = tx.Model(&completion).WherePK().Delete()
+		}
+		if err != nil {
+			return err
+		}
+		// enrollments are listed by the latest activity
+		_, err = tx.Model(&Enrollment{}).
+			Set("updated_at = now()").
+			Where("collection_id = ? AND user_id = ?", collectionId, int64(userId)).
+			Update()
+		if err != nil {
+			return err
+		}
+		progress, err = learningProgress(tx, collectionId, int64(userId))
+		return err
+	})
+	if err != nil {
+		respondWithLearningError(w, err)
+		return
+	}
+	if *payload.Completed {
+		progress["message"] = "Step completed"
+	} else {
+		progress["message"] = "Step marked as not completed"
+	}
+	utils.RespondWithJson(w, http.StatusOK, progress)
+}
+
+// GetLearningProgress get the steps of a learning path the user is
+// enrolled in, the percentage completed and the step to continue with
+func (h *Handler) GetLearningProgress(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	var progress map[string]interface{}
+	err := checkEnrollment(h.Db, collectionId, int64(userId))
+	if err == nil {
+		progress, err = learningProgress(h.Db, collectionId, int64(userId))
+	}
+	if err != nil {
+		respondWithLearningError(w, err)
+	} else {
+		utils.RespondWithJson(w, http.StatusOK, progress)
+	}
+}
+
+// GetEnrollments get the learning paths the user is enrolled in and can
+// still view, with the progress of the user, latest activity first
+func (h *Handler) GetEnrollments(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	pager := orm.NewPager(r.URL.Query())
+	enrolled := `enrollment.user_id = ?0 AND collection.learning_path AND ` +
+		collectionVisibility("collection")
+
+	count, err := h.Db.Model(&Enrollment{}).
+		Join("JOIN collections AS collection ON collection.id = enrollment.collection_id").
+		Where(enrolled, int64(userId)).
+		Count()
+	var enrollments []*EnrollmentProgress
+	if err == nil {
+		_, err = h.Db.Query(&enrollments, fmt.Sprintf(`SELECT collection.id,
+			collection.name, enrollment.created_at AS enrolled_at,
+			(SELECT COUNT(*) FROM resource_collections AS rc
+				JOIN resources AS resource ON resource.id = rc.resource_id
+				WHERE rc.collection_id = collection.id AND %[2]s) AS total_steps,
+			(SELECT COUNT(*) FROM step_completions AS sc
+				JOIN resource_collections AS rc USING (collection_id, resource_id)
+				JOIN resources AS resource ON resource.id = rc.resource_id
+				WHERE sc.collection_id = collection.id AND sc.user_id = ?0
+				AND %[2]s) AS completed_steps
+		FROM enrollments AS enrollment
+		JOIN collections AS collection ON collection.id = enrollment.collection_id
+		WHERE %[1]s
+		ORDER BY enrollment.updated_at DESC, collection.id DESC
+		LIMIT ?1 OFFSET ?2`, enrolled, resourceVisibility("resource")),
+			int64(userId), pager.GetLimit(), pager.GetOffset(),
+		)
+	}
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	for _, enrollment := range enrollments {
+		enrollment.Progress = progressPercent(
+			enrollment.CompletedSteps, enrollment.TotalSteps,
+		)
+	}
+	payload := map[string]interface{}{
+		"totalCount":  count,
+		"enrollments": enrollments,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
+
+// GetLearningStats get how far the users enrolled in a learning path of the
+// user got: how many completed it, their average progress and how many
+// completed each step
+func (h *Handler) GetLearningStats(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	collectionId, _ := strconv.ParseInt(mux.Vars(r)["collectionId"], 10, 64)
+	_, role, err := viewLearningPath(h.Db, collectionId, int64(userId))
+	if err == nil && role != collectionOwner {
+		err = errNotLearningOwner
+	}
+	// the steps are those the owner can access, like the learners see them
+	var steps []*LearningPathStepStats
+	if err == nil {
+		_, err = h.Db.Query(&steps, fmt.Sprintf(`SELECT rc.resource_id, rc.position,
+			rc.section, resource.title, resource.type, resource.link,
+			(SELECT COUNT(*) FROM step_completions AS sc
+				JOIN enrollments AS enrollment USING (collection_id, user_id)
+				WHERE sc.collection_id = rc.collection_id
+				AND sc.resource_id = rc.resource_id) AS completed_count
+		FROM resource_collections AS rc
+		JOIN resources AS resource ON resource.id = rc.resource_id
+		WHERE rc.collection_id = ?1 AND %s
+		ORDER BY rc.position ASC`, resourceVisibility("resource")),
+			int64(userId), collectionId,
+		)
+	}
+	var enrolledCount, completedCount int
+	var averageCompleted float64
+	if err == nil {
+		_, err = h.Db.QueryOne(
+			pg.Scan(&enrolledCount, &completedCount, &averageCompleted),
+			fmt.Sprintf(`SELECT COUNT(*),
+				COUNT(*) FILTER (WHERE completed >= ?2 AND ?2 > 0),
+				COALESCE(AVG(completed), 0)
+			FROM (SELECT (SELECT COUNT(*) FROM step_completions AS sc
+				JOIN resource_collections AS rc USING (collection_id, resource_id)
+				JOIN resources AS resource ON resource.id = rc.resource_id
+				WHERE sc.collection_id = enrollment.collection_id
+				AND sc.user_id = enrollment.user_id AND %s) AS completed
+			FROM enrollments AS enrollment
+			WHERE enrollment.collection_id = ?1) AS progress`,
+				resourceVisibility("resource"),
+			),
+			int64(userId), collectionId, len(steps),
+		)
+	}
+	if err != nil {
+		respondWithLearningError(w, err)
+		return
+	}
+	averageProgress := 0
+	if len(steps) > 0 {
+		averageProgress = int(averageCompleted * 100 / float64(len(steps)))
+	}
+	payload := map[string]interface{}{
+		"enrolledCount":   enrolledCount,
+		"completedCount":  completedCount,
+		"averageProgress": averageProgress,
+		"totalSteps":      len(steps),
+		"steps":           steps,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"WeKnow_api/services"
+	"log"
+	"net/url"
+	"os"
+)
+
+// mailLink build a link to the page in the urlKey env var that handles
+// token; without a page the mail contains only the token
+func mailLink(urlKey, token string) string {
+	if page := os.Getenv(urlKey); page != "" {
+		return page + "?token=" + url.QueryEscape(token)
+	}
+	return token
+}
+
+// sendMail send an email in the background, so the response time does not
+// depend on the mail server or on whether an email was sent at all
+func (h *Handler) sendMail(mail services.Mail) {
+	go func() {
+		if err := h.Mailer.Send(mail); err != nil {
+			log.Printf("Could not send %q mail: %v", mail.Subject, err)
+		}
+	}()
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+)
+
+// mentionPattern match an @username that is not part of a word or an email
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// recordMentions store the @username mentions in the text of a comment, or
+// of the description of a resource when commentId is 0; users who cannot
+// see the resource or who block or are blocked by the author are ignored
+func recordMentions(
+	db orm.DB, authorId, resourceId, commentId int64, text string,
+) ([]*Mention, error) {
+	matches := mentionPattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	var usernames []string
+	for _, match := range matches {
+		usernames = append(usernames, text[match[2]:match[3]])
+	}
+	var users []User
+	_, err := db.Query(&users, `SELECT users.id, users.username
+	FROM users, resources AS resource
+	WHERE resource.id = ?0 AND users.username IN (?1) AND users.id != ?2
+	AND NOT EXISTS(SELECT * FROM blocks WHERE
+		(blocker_id = users.id AND blocked_id = ?2) OR
+		(blocker_id = ?2 AND blocked_id = users.id))
+	AND `+resourceVisibilityFor("resource", "users.id"),
+		resourceId, pg.In(usernames), authorId,
+	)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	userIds := map[string]int64{}
+	for _, user := range users {
+		userIds[user.Username] = user.Id
+	}
+	var mentions []*Mention
+	for _, match := range matches {
+		userId, ok := userIds[text[match[2]:match[3]]]
+		if !ok {
+			continue
+		}
+		// the mention starts at the @ right before the username
+		mentions = append(mentions, &Mention{
+			UserId:     userId,
+			ResourceId: resourceId,
+			CommentId:  commentId,
+			Offset:     utf8.RuneCountInString(text[:match[2]-1]),
+			Length:     utf8.RuneCountInString(text[match[2]-1 : match[3]]),
+		})
+	}
+	_, err = db.Model(&mentions).Insert()
+	return mentions, err
+}
+
+// replaceMentions replace the mentions of a comment, or of the description
+// of a resource when commentId is 0, and return the users who were already
+// mentioned
+func replaceMentions(
+	db orm.DB, authorId, resourceId, commentId int64, text string,
+) ([]*Mention, map[int64]bool, error) {
+	var previous []Mention
+	_, err := db.Model(&previous).
+		Where("resource_id = ? AND comment_id IS NOT DISTINCT FROM ?", resourceId, nullId(commentId)).
+		Returning("user_id").
+		Delete()
+	if err != nil {
+		return nil, nil, err
+	}
+	mentioned := map[int64]bool{}
+	for _, mention := range previous {
+		mentioned[mention.UserId] = true
+	}
+	mentions, err := recordMentions(db, authorId, resourceId, commentId, text)
+	return mentions, mentioned, err
+}
+
+// nullId get an id as a query parameter, NULL when it is 0
+func nullId(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// notifyMentions notify mentioned users once, except the users in skip
+func (h *Handler) notifyMentions(
+	authorId int64, mentions []*Mention, skip map[int64]bool,
+) {
+	notified := map[int64]bool{}
+	for userId := range skip {
+		notified[userId] = true
+	}
+	for _, mention := range mentions {
+		if notified[mention.UserId] {
+			continue
+		}
+		notified[mention.UserId] = true
+		h.notify(Notification{
+			UserId:     mention.UserId,
+			ActorId:    authorId,
+			Type:       MentionNotification,
+			ResourceId: mention.ResourceId,
+			CommentId:  mention.CommentId,
+		})
+	}
+}
+
+// setCommentMentions set the mentions of comments
+func (h *Handler) setCommentMentions(comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	byId := map[int64]*Comment{}
+	var commentIds []int64
+	for _, comment := range comments {
+		byId[comment.Id] = comment
+		commentIds = append(commentIds, comment.Id)
+	}
+	var mentions []*Mention
+	err := h.Db.Model(&mentions).
+		Where("comment_id IN (?)", pg.In(commentIds)).
+		Order("offset ASC").
+		Select()
+	for _, mention := range mentions {
+		comment := byId[mention.CommentId]
+		comment.Mentions = append(comment.Mentions, mention)
+	}
+	return err
+}
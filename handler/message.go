@@ -0,0 +1,242 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// Conversation summary of the messages between the user and another user
+type Conversation struct {
+	UserId        int64
+	Username      string
+	LastMessage   string
+	LastMessageAt time.Time
+	UnreadCount   int
+}
+
+// findConnection find the accepted connection between two users in either
+// direction, preferring the one initiated by userId
+func (h *Handler) findConnection(userId, otherUserId int64) (*Connection, error) {
+	var connection Connection
+	err := h.Db.Model(&connection).
+		Where(
+			`(initiator_id = ?0 AND recipient_id = ?1) OR
+			(initiator_id = ?1 AND recipient_id = ?0)`,
+			userId, otherUserId,
+		).
+		Where("status = ?", AcceptedConnection).
+		OrderExpr("initiator_id = ? DESC", userId).
+		Limit(1).
+		Select()
+	return &connection, err
+}
+
+// SendMessage send a message to a connected user
+func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct {
+		RecipientId int64
+		Content     string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"Invalid field(s) in request payload",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	message := Message{
+		SenderId:    int64(userId),
+		RecipientId: payload.RecipientId,
+		Content:     payload.Content,
+	}
+	if err := utils.ValidateNewMessage(&message); err != nil {
+		utils.RespondWithJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if blocked, err := h.isBlocked(message.SenderId, message.RecipientId); err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	} else if blocked {
+		utils.RespondWithError(
+			w, http.StatusForbidden,
+			"You cannot message this user",
+		)
+		return
+	}
+	connection, err := h.findConnection(message.SenderId, message.RecipientId)
+	if err != nil {
+		if err == pg.ErrNoRows {
+			utils.RespondWithError(
+				w, http.StatusForbidden,
+				"You can only message users you are connected with",
+			)
+		} else {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wrong",
+			)
+		}
+		return
+	}
+	message.ConnectionId = connection.Id
+	if err := h.Db.Insert(&message); err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	h.publish(message.RecipientId, message.SenderId, "message", message)
+	utils.RespondWithJson(w, http.StatusCreated, map[string]interface{}{
+		"sentMessage": message,
+		"message":     "Message sent",
+	})
+}
+
+// GetMessages get the messages between the user and another user
+func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	otherUserId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if otherUserId == 0 {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"Invalid user Id in request",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+
+	var messages []Message
+	count, err := h.Db.Model(&messages).
+		Where(
+			`(sender_id = ?0 AND recipient_id = ?1) OR
+			(sender_id = ?1 AND recipient_id = ?0)`,
+			int64(userId), otherUserId,
+		).
+		Order("created_at DESC", "id DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"messages":   messages,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetConversations get the conversations of the user, most recent first
+func (h *Handler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	pager := orm.NewPager(r.URL.Query())
+
+	var conversations []Conversation
+	_, err := h.Db.Query(&conversations, `SELECT conversation.*, users.username
+	FROM (
+		SELECT CASE WHEN m.sender_id = ?0
+			THEN m.recipient_id ELSE m.sender_id END AS user_id,
+			(array_agg(m.content ORDER BY m.created_at DESC, m.id DESC))[1]
+				AS last_message,
+			max(m.created_at) AS last_message_at,
+			count(*) FILTER (WHERE m.recipient_id = ?0 AND m.read_at IS NULL)
+				AS unread_count
+		FROM messages AS m
+		WHERE m.sender_id = ?0 OR m.recipient_id = ?0
+		GROUP BY 1
+	) AS conversation
+	JOIN users ON users.id = conversation.user_id
+	ORDER BY conversation.last_message_at DESC, conversation.user_id DESC
+	LIMIT ?1 OFFSET ?2`,
+		int64(userId), pager.GetLimit(), pager.GetOffset(),
+	)
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"conversations": conversations,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetUnreadMessagesCount get the number of unread messages of the user
+func (h *Handler) GetUnreadMessagesCount(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	count, err := h.Db.Model(&Message{}).
+		Where("recipient_id = ? AND read_at IS NULL", int64(userId)).
+		Count()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"unreadCount": count,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// MarkMessagesRead mark the messages received from a user as read
+func (h *Handler) MarkMessagesRead(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ UserId int64 }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.UserId == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid userId is required",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	res, err := h.Db.Model(&Message{}).
+		Set("read_at = ?", time.Now()).
+		Where(
+			"sender_id = ? AND recipient_id = ? AND read_at IS NULL",
+			payload.UserId, int64(userId),
+		).
+		Update()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		if res.RowsAffected() > 0 {
+			h.publish(payload.UserId, int64(userId), "read", map[string]interface{}{
+				"userId": int64(userId),
+			})
+		}
+		responsePayload := map[string]interface{}{
+			"message":   "Messages marked as read",
+			"readCount": res.RowsAffected(),
+		}
+		utils.RespondWithJson(w, http.StatusOK, responsePayload)
+	}
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// notify record a notification and push it to the user's streams, unless
+// the user caused it or turned its type off
+func (h *Handler) notify(notification Notification) {
+	if notification.UserId == notification.ActorId {
+		return
+	}
+	disabled, err := h.Db.Model(&NotificationSetting{}).
+		Where(
+			"user_id = ? AND type = ? AND NOT enabled",
+			notification.UserId, notification.Type,
+		).
+		Exists()
+	if err != nil || disabled {
+		return
+	}
+	if err := h.Db.Insert(&notification); err != nil {
+		log.Printf("Could not create %s notification: %v", notification.Type, err)
+		return
+	}
+	h.publish(
+		notification.UserId, notification.ActorId,
+		notification.Type, notification,
+	)
+}
+
+// GetNotifications get the notifications of the user, newest first
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	queryValues := r.URL.Query()
+
+	var notifications []Notification
+	query := h.Db.Model(&notifications).
+		Column("notification.*").
+		ColumnExpr("actor.username AS actor__username").
+		Join("JOIN users AS actor ON actor.id = notification.actor_id").
+		Where("notification.user_id = ?", int64(userId))
+	if queryValues.Get("unread") == "true" {
+		query = query.Where("notification.read_at IS NULL")
+	}
+	count, err := query.
+		Order("notification.created_at DESC", "notification.id DESC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	unreadCount, err := h.Db.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", int64(userId)).
+		Count()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":    count,
+			"unreadCount":   unreadCount,
+			"notifications": notifications,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// markNotificationsRead mark the notifications of the user matching
+// condition as read or unread
+func (h *Handler) markNotificationsRead(
+	w http.ResponseWriter, r *http.Request,
+	condition string, values ...interface{},
+) {
+	defer r.Body.Close()
+
+	var payload struct{ Read *bool }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Read == nil {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"A valid read value is required",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var readAt *time.Time
+	if *payload.Read {
+		now := time.Now()
+		readAt = &now
+	}
+	res, err := h.Db.Model(&Notification{}).
+		Set("read_at = ?", readAt).
+		Where("user_id = ?", int64(userId)).
+		Where(condition, values...).
+		Update()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		responsePayload := map[string]interface{}{
+			"message":      "Notifications updated",
+			"updatedCount": res.RowsAffected(),
+		}
+		utils.RespondWithJson(w, http.StatusOK, responsePayload)
+	}
+}
+
+// MarkNotificationRead mark a notification of the user as read or unread
+func (h *Handler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationId, _ := strconv.ParseInt(mux.Vars(r)["notificationId"], 10, 64)
+	if notificationId == 0 {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"Invalid notification Id in request",
+		)
+		return
+	}
+	h.markNotificationsRead(w, r, "id = ?", notificationId)
+}
+
+// MarkAllNotificationsRead mark all notifications of the user as read or unread
+func (h *Handler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	h.markNotificationsRead(w, r, "TRUE")
+}
+
+// GetNotificationSettings get which notification types the user receives
+func (h *Handler) GetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var settings []NotificationSetting
+	err := h.Db.Model(&settings).
+		Where("user_id = ?", int64(userId)).
+		Select()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, map[string]interface{}{
+		"settings": notificationSettingsMap(settings),
+	})
+}
+
+// UpdateNotificationSettings turn notification types on or off for the user
+func (h *Handler) UpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if err := utils.ValidateNotificationSettings(payload); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var settings, updatedSettings []NotificationSetting
+	for notificationType, enabled := range payload {
+		settings = append(settings, NotificationSetting{
+			UserId:  int64(userId),
+			Type:    notificationType,
+			Enabled: enabled.(bool),
+		})
+	}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(&settings).
+			OnConflict("(user_id, type) DO UPDATE").
+			Set("enabled = EXCLUDED.enabled").
+			Insert()
+		if err != nil {
+			return err
+		}
+		return tx.Model(&updatedSettings).
+			Where("user_id = ?", int64(userId)).
+			Select()
+	})
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+		return
+	}
+	utils.RespondWithJson(w, http.StatusOK, map[string]interface{}{
+		"settings": notificationSettingsMap(updatedSettings),
+		"message":  "Notification settings updated",
+	})
+}
+
+// notificationSettingsMap map every notification type to whether it is
+// enabled; types without a setting are enabled
+func notificationSettingsMap(settings []NotificationSetting) map[string]bool {
+	settingsMap := map[string]bool{}
+	for _, notificationType := range NotificationTypes {
+		settingsMap[notificationType] = true
+	}
+	for _, setting := range settings {
+		settingsMap[setting.Type] = setting.Enabled
+	}
+	return settingsMap
+}
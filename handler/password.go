@@ -0,0 +1,156 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	"WeKnow_api/services"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+var errInvalidResetToken = errors.New("Invalid or expired reset token")
+
+// passwordResetMail build the email with the link to reset a password
+func passwordResetMail(email, token string) services.Mail {
+	return services.Mail{
+		To:      email,
+		Subject: "Reset your WeKnow password",
+		Body: fmt.Sprintf(
+			"Use the link below to reset your password. It can be used once.\n\n%s\n\n"+
+				"If you did not ask to reset your password, you can ignore this email.",
+			mailLink("PASSWORD_RESET_URL", token),
+		),
+	}
+}
+
+// ForgotPassword email a single-use password reset link; the response is
+// the same whether or not the email is registered
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Email string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	payload.Email = strings.TrimSpace(payload.Email)
+	if err := utils.ValidateEmail(payload.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var user User
+	err := h.Db.Model(&user).
+		Column("id", "email").
+		Where("email = ?", payload.Email).
+		Select()
+	if err == nil {
+		err = h.createPasswordResetToken(&user)
+	}
+	if err != nil && err != pg.ErrNoRows {
+		log.Printf("Could not create password reset token: %v", err)
+	}
+	event := auditEvent(r, "requestPasswordReset", "user", user.Id)
+	event.Details = map[string]interface{}{"email": payload.Email}
+	h.Audit.Record(event)
+	utils.RespondWithSuccess(
+		w, http.StatusOK,
+		"If the email is registered, a password reset link has been sent",
+		"message",
+	)
+}
+
+// createPasswordResetToken replace the unused reset tokens of a user with a
+// new one and email it to the user
+func (h *Handler) createPasswordResetToken(user *User) error {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+	lifetime := TokenLifetime("PASSWORD_RESET_TTL", time.Hour)
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.Id).
+			Delete()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model(&PasswordResetToken{
+			UserId:    user.Id,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: time.Now().Add(lifetime),
+		}).Insert()
+		return err
+	})
+	if err == nil {
+		h.sendMail(passwordResetMail(user.Email, token))
+	}
+	return err
+}
+
+// ResetForgottenPassword set a new password with a password reset token and
+// end every session of the user
+func (h *Handler) ResetForgottenPassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Token, Password string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	payload.Password = strings.TrimSpace(payload.Password)
+	if payload.Token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A reset token is required")
+		return
+	}
+	if payload.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		var resetToken PasswordResetToken
+		err := tx.Model(&resetToken).
+			Where("token_hash = ?", utils.HashToken(payload.Token)).
+			Where("used_at IS NULL AND expires_at > now()").
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errInvalidResetToken
+		} else if err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err = tx.Model(&resetToken).
+			Set("used_at = ?", now).
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		user := User{
+			Id:                resetToken.UserId,
+			Password:          payload.Password,
+			PasswordChangedAt: &now,
+		}
+		_, err = tx.Model(&user).
+			Column("password", "password_changed_at", "updated_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		if err := revokeRefreshTokens(tx, user.Id); err != nil {
+			return err
+		}
+		event := auditEvent(r, "resetPassword", "user", user.Id)
+		event.ActorId = user.Id
+		return h.Audit.RecordTx(tx, event)
+	})
+	if err == errInvalidResetToken {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Password reset successfully", "message")
+	}
+}
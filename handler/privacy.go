@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg/orm"
+)
+
+// resourceVisibility SQL condition for the resources (aliased as alias)
+// the user bound to the first query parameter can access; hidden resources
+// are only visible to their owner
+func resourceVisibility(alias string) string {
+	return resourceVisibilityFor(alias, "?0")
+}
+
+// resourceVisibilityFor SQL condition for the resources (aliased as alias)
+// the user with the id of the SQL expression viewer can access
+func resourceVisibilityFor(alias, viewer string) string {
+	return fmt.Sprintf(`(%[1]s.user_id = %[2]s OR
+	(%[1]s.hidden_at IS NULL AND (%[1]s.privacy = 'public' OR
+	(%[1]s.privacy = 'followers' AND
+		(EXISTS(SELECT * FROM connections WHERE initiator_id = %[2]s AND
+			recipient_id = %[1]s.user_id AND status = 'accepted'))))))`, alias, viewer)
+}
+
+// visibleTo restrict a resource query to the resources a user can access
+func visibleTo(userId int64) func(*orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		return q.Where(resourceVisibility("resource"), userId), nil
+	}
+}
+
+// collectionVisibility SQL condition for the collections (aliased as alias)
+// the user bound to the first query parameter can view: their own, those
+// shared with them and public collections; hidden collections are only
+// visible to their owner and collaborators
+func collectionVisibility(alias string) string {
+	return fmt.Sprintf(`(%[1]s.user_id = ?0 OR
+	EXISTS(SELECT * FROM collection_collaborators WHERE collection_id = %[1]s.id
+		AND user_id = ?0 AND status = 'accepted') OR
+	(%[1]s.hidden_at IS NULL AND %[1]s.privacy = 'public'))`, alias)
+}
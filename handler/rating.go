@@ -0,0 +1,218 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errRatedResourceNotFound = errors.New(
+		"Either this resource does not exist or you cannot access it",
+	)
+	errCannotRateOwnResource = errors.New("You cannot rate your own resource")
+	errNotRated              = errors.New("You have not rated this resource")
+)
+
+// bayesianRating SQL expression ranking resources by their average rating
+// pulled towards the average of all ratings, by as many ratings as bound to
+// the first query parameter, so a few high ratings do not outrank many
+const bayesianRating = `(?0 * (SELECT COALESCE(AVG(stars), 0) FROM ratings) +
+	resource.rating_average * resource.rating_count) / (?0 + resource.rating_count)`
+
+// ratingPriorWeight get the number of average ratings the Bayesian average
+// of a resource starts from
+func ratingPriorWeight() int {
+	if weight, err := strconv.Atoi(os.Getenv("RATING_PRIOR_WEIGHT")); err == nil && weight > 0 {
+		return weight
+	}
+	return 5
+}
+
+// updateRatingSummary recount the ratings of a resource; resource gets the
+// new count and average. The resource must be locked so that concurrent
+// ratings are all counted
+func updateRatingSummary(db orm.DB, resource *Resource) error {
+	_, err := db.Model(resource).
+		Set(`(rating_count, rating_average) = (
+			SELECT COUNT(*), COALESCE(AVG(stars), 0)
+			FROM ratings WHERE resource_id = ?)`, resource.Id).
+		WherePK().
+		Returning("rating_count, rating_average").
+		Update()
+	return err
+}
+
+// respondWithRatingError respond to an error rating a resource
+func respondWithRatingError(w http.ResponseWriter, err error) {
+	switch err {
+	case errRatedResourceNotFound, errNotRated:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errCannotRateOwnResource:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// RateResource rate a resource with 1 to 5 stars and an optional review,
+// or change the rating of the user
+func (h *Handler) RateResource(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var rating Rating
+	if err := json.NewDecoder(r.Body).Decode(&rating); err != nil {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"stars must be a whole number from 1 to 5",
+		)
+		return
+	}
+	if err := utils.ValidateRating(&rating); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	rating.ResourceId, rating.UserId = resourceId, int64(userId)
+	rating.Resource, rating.User = nil, nil
+	rating.CreatedAt, rating.UpdatedAt = nil, nil
+	resource := Resource{Id: resourceId}
+
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&resource).
+			Column("resource.user_id").
+			Where("resource.id = ?", resourceId).
+			Apply(visibleTo(int64(userId))).
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errRatedResourceNotFound
+		} else if err != nil {
+			return err
+		}
+		if resource.UserId == int64(userId) {
+			return errCannotRateOwnResource
+		}
+		_, err = tx.Model(&rating).
+			OnConflict("(resource_id, user_id) DO UPDATE").
+			Set("stars = EXCLUDED.stars").
+			Set("review = EXCLUDED.review").
+			Set("updated_at = EXCLUDED.updated_at").
+			Insert()
+		if err != nil {
+			return err
+		}
+		return updateRatingSummary(tx, &resource)
+	})
+	if err != nil {
+		respondWithRatingError(w, err)
+		return
+	}
+	payload := map[string]interface{}{
+		"message":       "Resource rated",
+		"ratingCount":   resource.RatingCount,
+		"ratingAverage": resource.RatingAverage,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
+
+// RemoveRating remove the rating of the user on a resource
+func (h *Handler) RemoveRating(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	resource := Resource{Id: resourceId}
+
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&resource).
+			Column("resource.id").
+			WherePK().
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errNotRated
+		} else if err != nil {
+			return err
+		}
+		res, err := tx.Model(&Rating{}).
+			Where("resource_id = ? AND user_id = ?", resourceId, int64(userId)).
+			Delete()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotRated
+		}
+		return updateRatingSummary(tx, &resource)
+	})
+	if err != nil {
+		respondWithRatingError(w, err)
+		return
+	}
+	payload := map[string]interface{}{
+		"message":       "Rating removed",
+		"ratingCount":   resource.RatingCount,
+		"ratingAverage": resource.RatingAverage,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
+
+// GetRatings get the ratings and reviews of a resource, latest first,
+// optionally only those with a review
+func (h *Handler) GetRatings(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+
+	visible, err := h.Db.Model(&Resource{}).
+		Where("resource.id = ?", resourceId).
+		Apply(visibleTo(int64(userId))).
+		Exists()
+	if err == nil && !visible {
+		respondWithRatingError(w, errRatedResourceNotFound)
+		return
+	}
+	var ratings []Rating
+	count := 0
+	if err == nil {
+		query := h.Db.Model(&ratings).
+			Column(
+				"rating.stars",
+				"rating.review",
+				"rating.user_id",
+				"rating.created_at",
+				"rating.updated_at",
+				"User.id",
+				"User.username",
+			).
+			Where("rating.resource_id = ?", resourceId)
+		if queryValues.Get("reviewed") == "true" {
+			query = query.Where("rating.review != ''")
+		}
+		count, err = query.
+			Order("rating.updated_at DESC", "rating.user_id DESC").
+			Apply(orm.Pagination(queryValues)).
+			SelectAndCount()
+	}
+	if err != nil {
+		respondWithRatingError(w, err)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"ratings":    ratings,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
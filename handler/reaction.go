@@ -0,0 +1,169 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errAlreadyReacted = errors.New("You have already given this reaction")
+	errNotReacted     = errors.New("You have not given this reaction")
+	errCannotReact    = errors.New("You cannot react to this comment")
+)
+
+// updateReactionCount change the count of a reaction on a comment by delta
+// in a single statement, so concurrent reactions are all counted; comment
+// gets the new counts
+func updateReactionCount(db orm.DB, comment *Comment, reaction string, delta int) error {
+	query := db.Model(comment).WherePK().Returning("likes, reactions")
+	if reaction == LikeReaction {
+		query = query.Set("likes = likes + ?", delta)
+	} else {
+		query = query.Set(`reactions = CASE
+			WHEN COALESCE((reactions->>?0)::bigint, 0) + ?1 > 0
+			THEN jsonb_set(reactions, ARRAY[?0], to_jsonb(COALESCE((reactions->>?0)::bigint, 0) + ?1))
+			ELSE reactions - ?0
+		END`, reaction, delta)
+	}
+	_, err := query.Update()
+	return err
+}
+
+// setMyReactions set the reactions of a user on comments
+func (h *Handler) setMyReactions(userId int64, comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	byId := map[int64]*Comment{}
+	var commentIds []int64
+	for _, comment := range comments {
+		byId[comment.Id] = comment
+		commentIds = append(commentIds, comment.Id)
+	}
+	var reactions []CommentReaction
+	err := h.Db.Model(&reactions).
+		Column("comment_id", "reaction").
+		Where("user_id = ? AND comment_id IN (?)", userId, pg.In(commentIds)).
+		Order("created_at ASC").
+		Select()
+	for _, reaction := range reactions {
+		comment := byId[reaction.CommentId]
+		comment.MyReactions = append(comment.MyReactions, reaction.Reaction)
+	}
+	return err
+}
+
+// respondWithReactionError respond to an error reacting to a comment
+func respondWithReactionError(w http.ResponseWriter, err error) {
+	switch err {
+	case errCommentNotFound, errNotReacted:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errCannotReact:
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errAlreadyReacted:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	}
+}
+
+// ReactToComment like a comment or give it another reaction
+func (h *Handler) ReactToComment(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Reaction string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	if !utils.Contains(CommentReactions, payload.Reaction) {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"reaction must be one of "+strings.Join(CommentReactions, ", "),
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	comment := Comment{Id: commentId}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&comment).
+			Column("user_id").
+			WherePK().
+			Where("deleted_at IS NULL").
+			Where("hidden_at IS NULL OR user_id = ?", int64(userId)).
+			Select()
+		if err == pg.ErrNoRows {
+			return errCommentNotFound
+		} else if err != nil {
+			return err
+		}
+		if blocked, err := h.isBlocked(int64(userId), comment.UserId); err != nil {
+			return err
+		} else if blocked {
+			return errCannotReact
+		}
+		res, err := tx.Model(&CommentReaction{
+			CommentId: comment.Id,
+			UserId:    int64(userId),
+			Reaction:  payload.Reaction,
+		}).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errAlreadyReacted
+		}
+		return updateReactionCount(tx, &comment, payload.Reaction, 1)
+	})
+	if err != nil {
+		respondWithReactionError(w, err)
+		return
+	}
+	responsePayload := map[string]interface{}{
+		"likes":     comment.Likes,
+		"reactions": comment.Reactions,
+		"message":   "Reaction added",
+	}
+	utils.RespondWithJson(w, http.StatusOK, responsePayload)
+}
+
+// RemoveCommentReaction unlike a comment or take back another reaction
+func (h *Handler) RemoveCommentReaction(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	commentId, _ := strconv.ParseInt(mux.Vars(r)["commentId"], 10, 64)
+	reaction := mux.Vars(r)["reaction"]
+	comment := Comment{Id: commentId}
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		res, err := tx.Model(&CommentReaction{}).
+			Where("comment_id = ? AND user_id = ?", commentId, int64(userId)).
+			Where("reaction = ?", reaction).
+			Delete()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return errNotReacted
+		}
+		return updateReactionCount(tx, &comment, reaction, -1)
+	})
+	if err != nil {
+		respondWithReactionError(w, err)
+		return
+	}
+	responsePayload := map[string]interface{}{
+		"likes":     comment.Likes,
+		"reactions": comment.Reactions,
+		"message":   "Reaction removed",
+	}
+	utils.RespondWithJson(w, http.StatusOK, responsePayload)
+}
@@ -0,0 +1,420 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errReportNotFound = errors.New("Report does not exist")
+	errReportResolved = errors.New("Report is already resolved")
+	errReportClaimed  = errors.New("Report is claimed by another moderator")
+	errCannotHideUser = errors.New("Users cannot be hidden, warn or suspend them instead")
+	errReported       = errors.New("You have already reported this")
+)
+
+// reportTargetTables the table of each kind of reportable content
+var reportTargetTables = map[string]string{
+	"resource":   "resources",
+	"comment":    "comments",
+	"collection": "collections",
+	"user":       "users",
+}
+
+// reportedOwner get the user who owns reported content; a reported user
+// owns themselves
+func reportedOwner(db orm.DB, targetType string, targetId int64) (int64, error) {
+	column := "user_id"
+	if targetType == "user" {
+		column = "id"
+	}
+	var ownerId int64
+	_, err := db.QueryOne(
+		pg.Scan(&ownerId), "SELECT ? FROM ? WHERE id = ?",
+		pg.F(column), pg.F(reportTargetTables[targetType]), targetId,
+	)
+	return ownerId, err
+}
+
+// setReportedHidden hide reported content until it is reviewed, or show it
+func setReportedHidden(db orm.DB, targetType string, targetId int64, hidden bool) error {
+	var hiddenAt *time.Time
+	if hidden {
+		now := time.Now()
+		hiddenAt = &now
+	}
+	_, err := db.Exec(
+		"UPDATE ? SET hidden_at = ? WHERE id = ?",
+		pg.F(reportTargetTables[targetType]), hiddenAt, targetId,
+	)
+	return err
+}
+
+// reportHideThreshold get how many open reports hide content automatically
+func reportHideThreshold() int {
+	if threshold, err := strconv.Atoi(os.Getenv("REPORT_HIDE_THRESHOLD")); err == nil && threshold > 0 {
+		return threshold
+	}
+	return 3
+}
+
+// ReportContent report a resource, comment, collection or user; content
+// reported by enough users is hidden until a moderator reviews it
+func (h *Handler) ReportContent(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var report Report
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"Invalid field(s) in request payload",
+		)
+		return
+	}
+	if err := utils.ValidateNewReport(&report); err != nil {
+		utils.RespondWithJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	report = Report{
+		ReporterId: int64(userId),
+		TargetType: report.TargetType,
+		TargetId:   report.TargetId,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     OpenReport,
+	}
+	ownerId, err := reportedOwner(h.Db, report.TargetType, report.TargetId)
+	if err == pg.ErrNoRows {
+		utils.RespondWithError(w, http.StatusNotFound, errTargetMissing.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if ownerId == report.ReporterId {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"You cannot report your own content",
+		)
+		return
+	}
+	unresolved := "target_type = ? AND target_id = ? AND status != ?"
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		// a reporter has at most one unresolved report of some content
+		err := tx.Insert(&report)
+		if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23505" {
+			return errReported
+		} else if err != nil {
+			return err
+		}
+		if report.TargetType == "user" {
+			return nil
+		}
+		var reporters int
+		err = tx.Model(&Report{}).
+			ColumnExpr("COUNT(DISTINCT reporter_id)").
+			Where(unresolved, report.TargetType, report.TargetId, ResolvedReport).
+			Select(pg.Scan(&reporters))
+		if err != nil || reporters < reportHideThreshold() {
+			return err
+		}
+		return setReportedHidden(tx, report.TargetType, report.TargetId, true)
+	})
+	if err == errReported {
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	payload := map[string]interface{}{
+		"report":  report,
+		"message": "Report submitted",
+	}
+	utils.RespondWithJson(w, http.StatusCreated, payload)
+}
+
+// GetMyReports get the reports of the user and their outcome
+func (h *Handler) GetMyReports(w http.ResponseWriter, r *http.Request) {
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var reports []Report
+	count, err := h.Db.Model(&reports).
+		Where("reporter_id = ?", int64(userId)).
+		Order("created_at DESC", "id DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"reports":    reports,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetReports get the moderation queue, oldest reports first, optionally
+// filtered by status, target type and reason
+func (h *Handler) GetReports(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	var reports []Report
+	query := h.Db.Model(&reports).
+		Column("report.*", "Reporter.id", "Reporter.username")
+	for param, column := range map[string]string{
+		"status":     "report.status",
+		"targetType": "report.target_type",
+		"reason":     "report.reason",
+	} {
+		if value := queryValues.Get(param); value != "" {
+			query = query.Where("? = ?", pg.F(column), value)
+		}
+	}
+	count, err := query.
+		Order("report.created_at ASC", "report.id ASC").
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"reports":    reports,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// ClaimReport claim a report so other moderators leave it, or release it
+func (h *Handler) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	reportId, _ := strconv.ParseInt(mux.Vars(r)["reportId"], 10, 64)
+	var payload struct{ Claimed *bool }
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Claimed == nil {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			"Claimed must be true or false",
+		)
+		return
+	}
+	moderatorId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	query := h.Db.Model(&Report{}).Where("id = ?", reportId)
+	var res orm.Result
+	if *payload.Claimed {
+		res, err = query.
+			Set("status = ?, moderator_id = ?", ClaimedReport, int64(moderatorId)).
+			Where(
+				"status = ? OR (status = ? AND moderator_id = ?)",
+				OpenReport, ClaimedReport, int64(moderatorId),
+			).
+			Update()
+	} else {
+		res, err = query.
+			Set("status = ?, moderator_id = NULL", OpenReport).
+			Where("status = ? AND moderator_id = ?", ClaimedReport, int64(moderatorId)).
+			Update()
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if res.RowsAffected() == 0 {
+		exists, err := h.Db.Model(&Report{}).Where("id = ?", reportId).Exists()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		} else if !exists {
+			utils.RespondWithError(w, http.StatusNotFound, errReportNotFound.Error())
+		} else if *payload.Claimed {
+			utils.RespondWithError(
+				w, http.StatusConflict,
+				"Report is already claimed or resolved",
+			)
+		} else {
+			utils.RespondWithError(
+				w, http.StatusConflict,
+				"You have not claimed this report",
+			)
+		}
+		return
+	}
+	if *payload.Claimed {
+		utils.RespondWithSuccess(w, http.StatusOK, "Report claimed", "message")
+	} else {
+		utils.RespondWithSuccess(w, http.StatusOK, "Report released", "message")
+	}
+}
+
+// ResolveReport resolve every unresolved report of the reported content by
+// dismissing them, hiding the content, or warning or suspending its owner;
+// the reporters are notified of the outcome
+func (h *Handler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	reportId, _ := strconv.ParseInt(mux.Vars(r)["reportId"], 10, 64)
+	var payload struct{ Resolution, Note, Until string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	if !utils.Contains(ReportResolutions, payload.Resolution) {
+		utils.RespondWithError(
+			w, http.StatusBadRequest,
+			"resolution must be one of "+strings.Join(ReportResolutions, ", "),
+		)
+		return
+	}
+	until := time.Now().Add(7 * 24 * time.Hour)
+	if payload.Resolution == "suspend" && payload.Until != "" {
+		date, err := utils.ParseDate(payload.Until)
+		if err != nil || !date.After(time.Now()) {
+			utils.RespondWithError(
+				w, http.StatusBadRequest,
+				"until must be a future date",
+			)
+			return
+		}
+		until = date
+	}
+	moderatorId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+
+	var report Report
+	var resolved []Report
+	var ownerId int64
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&report).
+			Where("id = ?", reportId).
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errReportNotFound
+		} else if err != nil {
+			return err
+		}
+		if report.Status == ResolvedReport {
+			return errReportResolved
+		}
+		if report.Status == ClaimedReport && report.ModeratorId != int64(moderatorId) {
+			return errReportClaimed
+		}
+		// reports of deleted content can only be dismissed
+		ownerId, err = reportedOwner(tx, report.TargetType, report.TargetId)
+		targetExists := err == nil
+		if err == pg.ErrNoRows && payload.Resolution == "dismiss" {
+			err = nil
+		} else if err == pg.ErrNoRows {
+			return errTargetMissing
+		} else if err != nil {
+			return err
+		}
+		switch payload.Resolution {
+		case "dismiss":
+			if targetExists && report.TargetType != "user" {
+				err = setReportedHidden(tx, report.TargetType, report.TargetId, false)
+			}
+		case "hide":
+			if report.TargetType == "user" {
+				return errCannotHideUser
+			}
+			err = setReportedHidden(tx, report.TargetType, report.TargetId, true)
+		case "suspend":
+			if _, err = lockUserOutrankedBy(tx, r, ownerId); err == nil {
+				err = suspendUser(tx, ownerId, &until)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model(&resolved).
+			Set("status = ?", ResolvedReport).
+			Set("resolution = ?, note = ?", payload.Resolution, payload.Note).
+			Set("moderator_id = ?, resolved_at = now()", int64(moderatorId)).
+			Where(
+				"target_type = ? AND target_id = ? AND status != ?",
+				report.TargetType, report.TargetId, ResolvedReport,
+			).
+			Returning("id, reporter_id").
+			Update()
+		if err != nil {
+			return err
+		}
+		var reportIds []int64
+		for _, resolvedReport := range resolved {
+			reportIds = append(reportIds, resolvedReport.Id)
+		}
+		details := map[string]interface{}{
+			"resolution": payload.Resolution,
+			"note":       payload.Note,
+			"reportIds":  reportIds,
+		}
+		if payload.Resolution == "suspend" {
+			details["until"] = until
+		}
+		return h.recordAdminAction(
+			tx, r, "resolveReport", report.TargetType, report.TargetId, details,
+		)
+	})
+	switch err {
+	case nil:
+	case errReportResolved, errReportClaimed:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	case errReportNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case errCannotHideUser:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		respondWithAdminError(w, err)
+		return
+	}
+
+	for _, resolvedReport := range resolved {
+		h.notify(Notification{
+			UserId:   resolvedReport.ReporterId,
+			ActorId:  int64(moderatorId),
+			Type:     ReportNotification,
+			ReportId: resolvedReport.Id,
+		})
+	}
+	if payload.Resolution == "warn" {
+		warning := Notification{
+			UserId:   ownerId,
+			ActorId:  int64(moderatorId),
+			Type:     WarningNotification,
+			ReportId: report.Id,
+		}
+		switch report.TargetType {
+		case "resource":
+			warning.ResourceId = report.TargetId
+		case "comment":
+			warning.CommentId = report.TargetId
+		case "collection":
+			warning.CollectionId = report.TargetId
+		}
+		h.notify(warning)
+	}
+	responsePayload := map[string]interface{}{
+		"message":       "Report resolved",
+		"resolvedCount": len(resolved),
+	}
+	utils.RespondWithJson(w, http.StatusOK, responsePayload)
+}
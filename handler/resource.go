@@ -2,13 +2,16 @@ package handler
 
 import (
 	. "WeKnow_api/model"
+	"WeKnow_api/services"
 	utils "WeKnow_api/utilities"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
 )
@@ -68,6 +71,17 @@ func (h *Handler) PostResource(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			}
+			resource.Mentions, err = recordMentions(
+				h.Db, resource.UserId, resource.Id, 0, resource.Description,
+			)
+			if err != nil {
+				utils.RespondWithError(
+					w, http.StatusInternalServerError,
+					"Oops! we couldn't record the mentions in the description",
+				)
+				return
+			}
+			h.notifyMentions(resource.UserId, resource.Mentions, nil)
 			payload := map[string]interface{}{
 				"resource": resource.Resource,
 				"tags":     resource.Tags,
@@ -103,6 +117,23 @@ func (h *Handler) UpdateResource(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
+	current := Resource{Id: resourceId, UserId: int64(userId)}
+	err := h.Db.Model(&current).
+		Column("title", "link", "type", "privacy", "description").
+		Where("id = ?id AND user_id = ?user_id").
+		Select()
+	if err == pg.ErrNoRows {
+		utils.RespondWithError(
+			w, http.StatusNotFound,
+			"Either this resource does not exist or you cannot access it",
+		)
+		return
+	} else if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError, "Something went wrong",
+		)
+		return
+	}
 	resource := &Resource{Id: resourceId, UserId: int64(userId)}
 	updatedFields := []string{}
 	for key, value := range payload {
@@ -119,6 +150,9 @@ func (h *Handler) UpdateResource(w http.ResponseWriter, r *http.Request) {
 		case "privacy":
 			resource.Privacy = value.(string)
 			updatedFields = append(updatedFields, "privacy")
+		case "description":
+			resource.Description = strings.TrimSpace(value.(string))
+			updatedFields = append(updatedFields, "description")
 		}
 	}
 	if len(updatedFields) > 0 {
@@ -185,6 +219,39 @@ func (h *Handler) UpdateResource(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	after := map[string]interface{}{}
+	for _, field := range updatedFields {
+		after[field] = payload[field]
+	}
+	if _, ok := payload["description"]; ok {
+		mentions, mentioned, err := replaceMentions(
+			h.Db, resource.UserId, resource.Id, 0, resource.Description,
+		)
+		if err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Oops! we couldn't record the mentions in the description",
+			)
+			return
+		}
+		resource.Mentions = mentions
+		h.notifyMentions(resource.UserId, mentions, mentioned)
+	}
+	event := auditEvent(r, "updateResource", "resource", resourceId)
+	event.Before, event.After = services.Diff(map[string]interface{}{
+		"title":       current.Title,
+		"link":        current.Link,
+		"type":        current.Type,
+		"privacy":     current.Privacy,
+		"description": current.Description,
+	}, after)
+	if len(addedTagTitles) > 0 || len(removedTagTitles) > 0 {
+		event.Details = map[string]interface{}{
+			"addedTags":   addedTagTitles,
+			"removedTags": removedTagTitles,
+		}
+	}
+	h.Audit.Record(event)
 	responsePayload := map[string]interface{}{
 		"updatedResource": resource,
 		"addedTags":       addedTagTitles,
@@ -211,8 +278,9 @@ func (h *Handler) DeleteResource(w http.ResponseWriter, r *http.Request) {
 	res, err := h.Db.
 		Model(&resource).
 		Where("id = ?id AND user_id = ?user_id").
+		Returning("title, link, type, privacy").
 		Delete()
-	if res.RowsAffected() == 0 {
+	if err == pg.ErrNoRows || (err == nil && res.RowsAffected() == 0) {
 		utils.RespondWithError(
 			w,
 			http.StatusForbidden,
@@ -227,6 +295,14 @@ func (h *Handler) DeleteResource(w http.ResponseWriter, r *http.Request) {
 			"Something went wrong",
 		)
 	} else {
+		event := auditEvent(r, "deleteResource", "resource", resourceId)
+		event.Before = map[string]interface{}{
+			"title":   resource.Title,
+			"link":    resource.Link,
+			"type":    resource.Type,
+			"privacy": resource.Privacy,
+		}
+		h.Audit.Record(event)
 		payload := map[string]interface{}{
 			"message":         "Resource deleted successfully",
 			"deletedResource": resourceId,
@@ -249,13 +325,14 @@ func (h *Handler) RecommendResource(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
-	var recommendationCount int64
+	var recommendationCount, resourceOwnerId int64
 
 	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
 		_, err := tx.QueryOne(
-			pg.Scan(&recommendationCount),
-			`SELECT recommendations FROM resources WHERE id = ? FOR UPDATE`,
-			resourceId,
+			pg.Scan(&recommendationCount, &resourceOwnerId),
+			`SELECT recommendations, user_id FROM resources AS resource
+			WHERE id = ?1 AND `+resourceVisibility("resource")+` FOR UPDATE`,
+			int64(userId), resourceId,
 		)
 		if err != nil {
 			return err
@@ -286,6 +363,12 @@ func (h *Handler) RecommendResource(w http.ResponseWriter, r *http.Request) {
 			)
 		}
 	} else {
+		h.notify(Notification{
+			UserId:     resourceOwnerId,
+			ActorId:    int64(userId),
+			Type:       RecommendationNotification,
+			ResourceId: resourceId,
+		})
 		payload := map[string]interface{}{
 			"message":             "Recommend resource successful",
 			"recommendationCount": recommendationCount,
@@ -295,6 +378,143 @@ func (h *Handler) RecommendResource(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// WithdrawRecommendation withdraw the recommendation of a resource
+func (h *Handler) WithdrawRecommendation(w http.ResponseWriter, r *http.Request) {
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	var recommendationCount int64
+
+	err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.QueryOne(
+			pg.Scan(&recommendationCount),
+			`SELECT recommendations FROM resources WHERE id = ? FOR UPDATE`,
+			resourceId,
+		)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Model(&Recommendation{}).
+			Where("resource_id = ? AND user_id = ?", resourceId, int64(userId)).
+			Delete()
+		if err != nil {
+			return err
+		} else if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		if recommendationCount > 0 {
+			recommendationCount--
+		}
+		_, err = tx.Exec(
+			`UPDATE resources SET recommendations = ?1 WHERE id = ?0`,
+			resourceId, recommendationCount,
+		)
+		return err
+	})
+	if err != nil {
+		if err == pg.ErrNoRows {
+			utils.RespondWithError(
+				w, http.StatusNotFound,
+				"You have not recommended this resource",
+			)
+		} else {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError, "Something went wrong",
+			)
+		}
+	} else {
+		payload := map[string]interface{}{
+			"message":             "Recommendation withdrawn",
+			"recommendationCount": recommendationCount,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetRecommenders get the users who recommended a resource, latest first
+func (h *Handler) GetRecommenders(w http.ResponseWriter, r *http.Request) {
+	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+
+	visible, err := h.Db.Model(&Resource{}).
+		Where("resource.id = ?", resourceId).
+		Apply(visibleTo(int64(userId))).
+		Exists()
+	if err == nil && !visible {
+		utils.RespondWithError(
+			w,
+			http.StatusNotFound,
+			"Either this resource does not exist or you cannot access it",
+		)
+		return
+	}
+	var recommendations []Recommendation
+	count := 0
+	if err == nil {
+		count, err = h.Db.Model(&recommendations).
+			Column(
+				"recommendation.user_id",
+				"recommendation.created_at",
+				"User.id",
+				"User.username",
+			).
+			Where("recommendation.resource_id = ?", resourceId).
+			Order("recommendation.created_at DESC").
+			Apply(orm.Pagination(r.URL.Query())).
+			SelectAndCount()
+	}
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":   count,
+			"recommenders": recommendations,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
+// GetRecommendedResources get the resources a user recommended, latest
+// first, leaving out those the user of the request cannot access
+func (h *Handler) GetRecommendedResources(w http.ResponseWriter, r *http.Request) {
+	recommenderId, _ := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+
+	var recommendations []Recommendation
+	count, err := h.Db.Model(&recommendations).
+		Column(
+			"recommendation.resource_id",
+			"recommendation.created_at",
+			"Resource.id",
+			"Resource.title",
+			"Resource.link",
+			"Resource.type",
+			"Resource.privacy",
+			"Resource.user_id",
+			"Resource.recommendations",
+		).
+		Where("recommendation.user_id = ?", recommenderId).
+		Where(resourceVisibility("resource"), int64(userId)).
+		Order("recommendation.created_at DESC").
+		Apply(orm.Pagination(r.URL.Query())).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount":      count,
+			"recommendations": recommendations,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+}
+
 // GetResource get a resource
 func (h *Handler) GetResource(w http.ResponseWriter, r *http.Request) {
 	resourceId, _ := strconv.ParseInt(mux.Vars(r)["resourceId"], 10, 64)
@@ -309,13 +529,6 @@ func (h *Handler) GetResource(w http.ResponseWriter, r *http.Request) {
 	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
 	resource := Resource{Id: resourceId}
 
-	condition := `resource.id = ?0 AND
-	(resource.privacy = 'public' OR
-	resource.user_id = ?1 OR
-	(resource.privacy = 'followers' AND
-		(EXISTS(SELECT * FROM connections WHERE initiator_id = ?1 AND
-			recipient_id = resource.user_id))))
-	`
 	err := h.Db.
 		Model(&resource).
 		Column("resource.*", "Tags").
@@ -324,7 +537,8 @@ func (h *Handler) GetResource(w http.ResponseWriter, r *http.Request) {
 			a_user.email AS user__email`,
 		).
 		Join("JOIN users AS a_user ON a_user.id = resource.user_id").
-		Where(condition, resource.Id, int64(userId)).
+		Where("resource.id = ?", resource.Id).
+		Apply(visibleTo(int64(userId))).
 		Select()
 
 	if err != nil {
@@ -341,7 +555,17 @@ func (h *Handler) GetResource(w http.ResponseWriter, r *http.Request) {
 			http.StatusInternalServerError,
 			"Something went wrong",
 		)
+	} else if err := h.Db.Model(&resource.Mentions).
+		Where("resource_id = ? AND comment_id IS NULL", resource.Id).
+		Order("offset ASC").
+		Select(); err != nil {
+		utils.RespondWithError(
+			w,
+			http.StatusInternalServerError,
+			"Something went wrong",
+		)
 	} else {
+		h.Views.Record(resource.Id, int64(userId))
 		payload := map[string]interface{}{
 			"resource": resource,
 		}
@@ -349,3 +573,89 @@ func (h *Handler) GetResource(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+// resourceSortColumns map sort query values to resource columns
+var resourceSortColumns = map[string]string{
+	"views":           "views",
+	"recommendations": "recommendations",
+	"createdAt":       "created_at",
+}
+
+// GetResources get resources visible to the user, filtered and sorted
+func (h *Handler) GetResources(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	filters, err := utils.ValidateResourceFilters(queryValues)
+	if err != nil {
+		utils.RespondWithError(
+			w,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+
+	var resources []Resource
+	query := h.Db.
+		Model(&resources).
+		Column("resource.*", "Tags").
+		ColumnExpr(
+			`a_user.username AS user__username,
+			a_user.email AS user__email`,
+		).
+		Join("JOIN users AS a_user ON a_user.id = resource.user_id").
+		Apply(visibleTo(int64(userId)))
+
+	if filters.Type != "" {
+		query = query.Where("resource.type = ?", filters.Type)
+	}
+	if filters.Tag != "" {
+		query = query.Where(
+			`EXISTS(SELECT * FROM resource_tags AS rt
+			JOIN tags ON tags.id = rt.tag_id
+			WHERE rt.resource_id = resource.id AND tags.title = ?)`,
+			strings.Title(filters.Tag),
+		)
+	}
+	if filters.AuthorId != 0 {
+		query = query.Where("resource.user_id = ?", filters.AuthorId)
+	}
+	if filters.From != nil {
+		query = query.Where("resource.created_at >= ?", *filters.From)
+	}
+	if filters.To != nil && filters.ToDate {
+		query = query.Where("resource.created_at < ?", filters.To.AddDate(0, 0, 1))
+	} else if filters.To != nil {
+		query = query.Where("resource.created_at <= ?", *filters.To)
+	}
+
+	order := strings.ToUpper(filters.Order)
+	if filters.Sort == "rating" {
+		query = query.OrderExpr(bayesianRating+" "+order, ratingPriorWeight())
+	} else {
+		sortColumn := resourceSortColumns[filters.Sort]
+		if sortColumn == "" {
+			sortColumn = resourceSortColumns["createdAt"]
+		}
+		query = query.Order("resource." + sortColumn + " " + order)
+	}
+
+	count, err := query.
+		Order("resource.id " + order).
+		Apply(orm.Pagination(queryValues)).
+		SelectAndCount()
+	if err != nil {
+		utils.RespondWithError(
+			w,
+			http.StatusInternalServerError,
+			"Something went wrong",
+		)
+	} else {
+		payload := map[string]interface{}{
+			"totalCount": count,
+			"resources":  resources,
+		}
+		utils.RespondWithJson(w, http.StatusOK, payload)
+	}
+	return
+}
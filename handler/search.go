@@ -0,0 +1,138 @@
+package handler
+
+import (
+	utils "WeKnow_api/utilities"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg/orm"
+	"github.com/gorilla/context"
+)
+
+// ResourceSearchResult a resource matching a search query
+type ResourceSearchResult struct {
+	Id      int64
+	UserId  int64
+	Title   string
+	Type    string
+	Privacy string
+	Rank    float64
+}
+
+// TagSearchResult a tag matching a search query
+type TagSearchResult struct {
+	Id    int64
+	Title string
+	Rank  float64
+}
+
+// CollectionSearchResult a collection matching a search query
+type CollectionSearchResult struct {
+	Id     int64
+	UserId int64
+	Name   string
+	Rank   float64
+}
+
+// UserSearchResult a user matching a search query
+type UserSearchResult struct {
+	Id       int64
+	Username string
+	Rank     float64
+}
+
+// Search search resources, tags, collections and users
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	if err := utils.ValidateSearchQuery(queryValues); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	limit := orm.NewPager(queryValues).GetLimit()
+	if limit < 1 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit in request")
+		return
+	}
+	text := strings.TrimSpace(queryValues.Get("q"))
+	searchType := queryValues.Get("type")
+
+	var resources []ResourceSearchResult
+	var tags []TagSearchResult
+	var collections []CollectionSearchResult
+	var users []UserSearchResult
+	searches := []struct {
+		entity string
+		result interface{}
+		query  string
+	}{
+		{"resources", &resources, fmt.Sprintf(`SELECT resource.id,
+			resource.user_id, resource.title, resource.type, resource.privacy,
+			ts_rank(resource.search_vector, query) + COALESCE(
+				(SELECT max(ts_rank(tags.search_vector, query))
+				FROM resource_tags AS rt JOIN tags ON tags.id = rt.tag_id
+				WHERE rt.resource_id = resource.id AND tags.search_vector @@ query),
+				0) AS rank
+		FROM resources AS resource,
+			plainto_tsquery('pg_catalog.english', ?1) AS query
+		WHERE (resource.search_vector @@ query OR
+			EXISTS(SELECT * FROM resource_tags AS rt
+				JOIN tags ON tags.id = rt.tag_id
+				WHERE rt.resource_id = resource.id AND tags.search_vector @@ query))
+			AND %s
+		ORDER BY rank DESC, resource.id DESC
+		LIMIT ?2`, resourceVisibility("resource"))},
+		{"tags", &tags, `SELECT tags.id, tags.title,
+			ts_rank(tags.search_vector, query) AS rank
+		FROM tags, plainto_tsquery('pg_catalog.english', ?1) AS query
+		WHERE tags.search_vector @@ query
+		ORDER BY rank DESC, tags.id DESC
+		LIMIT ?2`},
+		{"collections", &collections, fmt.Sprintf(`SELECT collection.id,
+			collection.user_id, collection.name,
+			ts_rank(collection.search_vector, query) + COALESCE(
+				(SELECT max(ts_rank(tags.search_vector, query))
+				FROM collection_tags AS ct JOIN tags ON tags.id = ct.tag_id
+				WHERE ct.collection_id = collection.id AND tags.search_vector @@ query),
+				0) AS rank
+		FROM collections AS collection,
+			plainto_tsquery('pg_catalog.english', ?1) AS query
+		WHERE (collection.search_vector @@ query OR
+			EXISTS(SELECT * FROM collection_tags AS ct
+				JOIN tags ON tags.id = ct.tag_id
+				WHERE ct.collection_id = collection.id AND tags.search_vector @@ query))
+			AND %s
+		ORDER BY rank DESC, collection.id DESC
+		LIMIT ?2`, collectionVisibility("collection"))},
+		{"users", &users, `SELECT users.id, users.username,
+			ts_rank(users.search_vector, query) AS rank
+		FROM users, plainto_tsquery('pg_catalog.simple', ?1) AS query
+		WHERE users.search_vector @@ query
+		ORDER BY rank DESC, users.id DESC
+		LIMIT ?2`},
+	}
+
+	for _, search := range searches {
+		if searchType != "" && searchType != search.entity {
+			continue
+		}
+		_, err := h.Db.Query(search.result, search.query, int64(userId), text, limit)
+		if err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wrong",
+			)
+			return
+		}
+	}
+
+	payload := map[string]interface{}{
+		"resources":   resources,
+		"tags":        tags,
+		"collections": collections,
+		"users":       users,
+	}
+	utils.RespondWithJson(w, http.StatusOK, payload)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"WeKnow_api/services"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/context"
+)
+
+// publish send a real-time event to a user unless the user caused it
+func (h *Handler) publish(userId, actorId int64, eventType string, data interface{}) {
+	if h.Hub == nil || userId == actorId {
+		return
+	}
+	h.Hub.Publish(userId, services.Event{Type: eventType, Data: data})
+}
+
+// Stream push real-time events to the user as server-sent events
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Streaming is not supported",
+		)
+		return
+	}
+	userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+	subscription := h.Hub.Subscribe(int64(userId))
+	defer h.Hub.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.Hub.HeartbeatInterval)
+	defer heartbeat.Stop()
+	idle := time.NewTimer(h.Hub.IdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(
+				w, "event: %s\ndata: %s\n\n", event.Type, data,
+			); err != nil {
+				return
+			}
+			flusher.Flush()
+			if !idle.Stop() {
+				// the timer fired while the event was written
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(h.Hub.IdleTimeout)
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-idle.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
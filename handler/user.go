@@ -2,6 +2,7 @@ package handler
 
 import (
 	. "WeKnow_api/model"
+	"WeKnow_api/services"
 	utils "WeKnow_api/utilities"
 	"fmt"
 	"strings"
@@ -25,18 +26,17 @@ func (h *Handler) UserSignUpEndPoint(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 	} else {
 		if err := utils.ValidateSignUpRequest(user); err == nil {
+			now := time.Now()
+			user.VerifiedAt, user.PendingEmail = nil, ""
+			user.Role, user.SuspendedUntil, user.BannedAt = UserRole, nil, nil
+			user.VerificationSentAt = &now
 			if err := h.Db.Insert(user); err != nil {
 				if err.(pg.Error).Field('C') == "23505" {
 					utils.RespondWithError(w, http.StatusConflict, "User already exists")
 				}
-			} else if token, err := user.GenerateToken(); err != nil {
-				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
 			} else {
-				payload := map[string]interface{}{
-					"token":   token,
-					"message": "Authentication successful",
-				}
-				utils.RespondWithJson(w, http.StatusOK, payload)
+				h.sendVerificationMail(user, user.Email)
+				h.respondWithTokens(w, r, user, http.StatusOK)
 			}
 		} else {
 			utils.RespondWithJsonError(w, http.StatusBadRequest, err.Error())
@@ -57,19 +57,24 @@ func (h *Handler) UserSignInEndPoint(w http.ResponseWriter, r *http.Request) {
 			var foundUser User
 			if err := h.Db.Model(&foundUser).Where("Email = ?", user.Email).Select(); err != nil {
 				if err.Error() == "pg: no rows in result set" {
+					h.auditSignInFailure(r, user.Email, 0, "unknownEmail")
 					utils.RespondWithError(w, http.StatusUnauthorized, "Invalid signin parameters")
 					return
 				}
 				utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 			} else {
 				if foundUser.CompareHashAndPassword(user.Password) == true {
-					token, _ := foundUser.GenerateToken()
-					payload := map[string]interface{}{
-						"token":   token,
-						"message": "Authentication successful",
+					if err := foundUser.Restriction(); err != nil {
+						h.auditSignInFailure(r, user.Email, foundUser.Id, "restricted")
+						utils.RespondWithError(w, http.StatusForbidden, err.Error())
+						return
 					}
-					utils.RespondWithJson(w, http.StatusOK, payload)
+					event := auditEvent(r, "signIn", "user", foundUser.Id)
+					event.ActorId = foundUser.Id
+					h.Audit.Record(event)
+					h.respondWithTokens(w, r, &foundUser, http.StatusOK)
 				} else {
+					h.auditSignInFailure(r, user.Email, foundUser.Id, "wrongPassword")
 					utils.RespondWithError(w, http.StatusUnauthorized, "Invalid signin parameters")
 					return
 				}
@@ -81,6 +86,14 @@ func (h *Handler) UserSignInEndPoint(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// auditSignInFailure record a failed sign in; userId is 0 when no user has
+// the email
+func (h *Handler) auditSignInFailure(r *http.Request, email string, userId int64, reason string) {
+	event := auditEvent(r, "signInFailed", "user", userId)
+	event.Details = map[string]interface{}{"email": email, "reason": reason}
+	h.Audit.Record(event)
+}
+
 // ConnectUser create a connection between two users
 func (h *Handler) ConnectUser(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -102,23 +115,57 @@ func (h *Handler) ConnectUser(w http.ResponseWriter, r *http.Request) {
 			)
 			return
 		}
+		if blocked, err := h.isBlocked(initiatorId, recipientId); err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wrong",
+			)
+			return
+		} else if blocked {
+			utils.RespondWithError(
+				w, http.StatusForbidden,
+				"You cannot connect with this user",
+			)
+			return
+		}
+		recipient := User{Id: recipientId}
+		if err := h.Db.Model(&recipient).Column("private").WherePK().Select(); err != nil {
+			if err == pg.ErrNoRows {
+				utils.RespondWithError(
+					w, http.StatusBadRequest,
+					"User does not exist",
+				)
+			} else {
+				utils.RespondWithError(
+					w, http.StatusInternalServerError,
+					"Something went wrong!",
+				)
+			}
+			return
+		}
 		connection := Connection{
 			InitiatorId: initiatorId,
 			RecipientId: recipientId,
+			Status:      AcceptedConnection,
+		}
+		// following a private account needs the approval of its owner
+		if recipient.Private {
+			connection.Status = PendingConnection
 		}
 		values := []interface{}{
 			connection.InitiatorId,
 			connection.RecipientId,
+			connection.Status,
 			time.Now(),
 			time.Now(),
 		}
 
 		q := `WITH connection as
-		(INSERT INTO connections(initiator_id, recipient_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4) RETURNING id)
+		(INSERT INTO connections(initiator_id, recipient_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id)
 		INSERT INTO user_connections(user_id, connection_id) VALUES`
 
-		i, userIds := 5, []int64{initiatorId, recipientId}
+		i, userIds := 6, []int64{initiatorId, recipientId}
 		for _, userId := range userIds {
 			q += fmt.Sprintf("($%d, (select connection.id from connection)),", i)
 			values = append(values, userId)
@@ -147,7 +194,21 @@ func (h *Handler) ConnectUser(w http.ResponseWriter, r *http.Request) {
 				)
 			}
 			return
+		} else if connection.Status == PendingConnection {
+			h.notify(Notification{
+				UserId:  recipientId,
+				ActorId: initiatorId,
+				Type:    FollowRequestNotification,
+			})
+			message := "follow request sent"
+			key := "message"
+			utils.RespondWithSuccess(w, http.StatusOK, message, key)
 		} else {
+			h.notify(Notification{
+				UserId:  recipientId,
+				ActorId: initiatorId,
+				Type:    FollowerNotification,
+			})
 			message := "connection successful"
 			key := "message"
 			utils.RespondWithSuccess(w, http.StatusOK, message, key)
@@ -173,6 +234,7 @@ func (h *Handler) GetAllFavorites(w http.ResponseWriter, r *http.Request) {
 			"Recipient.username",
 		).
 		Where("initiator_id = ?", int(userId)).
+		Where("status = ?", AcceptedConnection).
 		Apply(orm.Pagination(r.URL.Query())).
 		SelectAndCount()
 	if err != nil {
@@ -206,6 +268,7 @@ func (h *Handler) GetAllFollowers(w http.ResponseWriter, r *http.Request) {
 			"Initiator.username",
 		).
 		Where("recipient_id = ?", int(userId)).
+		Where("status = ?", AcceptedConnection).
 		Apply(orm.Pagination(r.URL.Query())).
 		SelectAndCount()
 	if err != nil {
@@ -238,6 +301,36 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if email, ok := user["email"]; ok {
+		taken, err := h.Db.Model(&User{}).
+			Where("email = ? AND id != ?", email, foundUser.Id).
+			Exists()
+		if err != nil {
+			utils.RespondWithError(
+				w, http.StatusInternalServerError,
+				"Something went wong",
+			)
+			return
+		} else if taken {
+			utils.RespondWithError(w, http.StatusConflict, "Email is already in use")
+			return
+		}
+	}
+	current := User{Id: foundUser.Id}
+	err := h.Db.Model(&current).
+		Column("username", "phone_number", "pending_email", "private").
+		WherePK().
+		Select()
+	if err == pg.ErrNoRows {
+		utils.RespondWithJsonError(w, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		utils.RespondWithError(
+			w, http.StatusInternalServerError,
+			"Something went wong",
+		)
+		return
+	}
 	updatedFields := []string{"updated_at"}
 	for key, value := range user {
 		switch key {
@@ -248,8 +341,14 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 			foundUser.PhoneNumber = value.(string)
 			updatedFields = append(updatedFields, "phone_number")
 		case "email":
-			foundUser.Email = value.(string)
-			updatedFields = append(updatedFields, "email")
+			// the email changes once the new address is verified
+			now := time.Now()
+			foundUser.PendingEmail = value.(string)
+			foundUser.VerificationSentAt = &now
+			updatedFields = append(updatedFields, "pending_email", "verification_sent_at")
+		case "private":
+			foundUser.Private = value.(bool)
+			updatedFields = append(updatedFields, "private")
 		}
 	}
 	res, err := h.Db.Model(foundUser).WherePK().Column(updatedFields...).Update()
@@ -259,6 +358,28 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 			utils.RespondWithJsonError(w, http.StatusNotFound, "User not found")
 			return
 		}
+		if email, ok := user["email"]; ok {
+			h.sendVerificationMail(foundUser, email.(string))
+			delete(user, "email")
+			user["pendingEmail"] = email
+		}
+		event := auditEvent(r, "updateProfile", "user", foundUser.Id)
+		event.Before, event.After = services.Diff(map[string]interface{}{
+			"username":     current.Username,
+			"phoneNumber":  current.PhoneNumber,
+			"pendingEmail": current.PendingEmail,
+			"private":      current.Private,
+		}, user)
+		h.Audit.Record(event)
+		// a public account has no use for pending follow requests
+		if private, ok := user["private"]; ok && !private.(bool) {
+			_, err = h.Db.Model(&Connection{}).
+				Set("status = ?", AcceptedConnection).
+				Where("recipient_id = ? AND status = ?", foundUser.Id, PendingConnection).
+				Update()
+		}
+	}
+	if err == nil {
 		payload := map[string]interface{}{
 			"updatedProfile": user,
 			"message":        "Profile Updated successfully",
@@ -290,8 +411,20 @@ func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	if user.Password != "" {
 		if err := h.Db.Select(foundUser); err == nil {
+			now := time.Now()
 			foundUser.Password = user.Password
-			if err := h.Db.Update(foundUser); err != nil {
+			// end existing sessions, so tokens issued before now are rejected
+			foundUser.PasswordChangedAt = &now
+			err := h.Db.RunInTransaction(func(tx *pg.Tx) error {
+				if err := tx.Update(foundUser); err != nil {
+					return err
+				}
+				if err := revokeRefreshTokens(tx, foundUser.Id); err != nil {
+					return err
+				}
+				return h.Audit.RecordTx(tx, auditEvent(r, "changePassword", "user", foundUser.Id))
+			})
+			if err != nil {
 				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
 				return
 			}
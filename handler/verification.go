@@ -0,0 +1,137 @@
+package handler
+
+import (
+	. "WeKnow_api/model"
+	"WeKnow_api/services"
+	utils "WeKnow_api/utilities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+var (
+	errInvalidVerificationLink = errors.New("Invalid or expired verification link")
+	errEmailInUse              = errors.New("Email is already in use")
+)
+
+// sendVerificationMail email a link that verifies email belongs to user
+func (h *Handler) sendVerificationMail(user *User, email string) {
+	token, err := user.GenerateVerificationToken(email)
+	if err != nil {
+		log.Printf("Could not create verification token: %v", err)
+		return
+	}
+	h.sendMail(services.Mail{
+		To:      email,
+		Subject: "Verify your WeKnow email address",
+		Body: fmt.Sprintf(
+			"Use the link below to verify your email address.\n\n%s",
+			mailLink("EMAIL_VERIFICATION_URL", token),
+		),
+	})
+}
+
+// VerifyEmail verify the email in a verification link; a pending email
+// replaces the current email of the user once verified
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	userId, email, err := ParseVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, errInvalidVerificationLink.Error())
+		return
+	}
+	err = h.Db.RunInTransaction(func(tx *pg.Tx) error {
+		user := User{Id: userId}
+		err := tx.Model(&user).
+			Column("email", "pending_email", "verified_at").
+			WherePK().
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			return errInvalidVerificationLink
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case user.PendingEmail != "" && email == user.PendingEmail:
+			_, err = tx.Model(&user).
+				Set("email = pending_email, pending_email = NULL").
+				Set("verified_at = COALESCE(verified_at, now())").
+				WherePK().
+				Update()
+			if pgError, OK := err.(pg.Error); OK && pgError.Field('C') == "23505" {
+				return errEmailInUse
+			} else if err != nil {
+				return err
+			}
+			event := auditEvent(r, "changeEmail", "user", user.Id)
+			event.ActorId = user.Id
+			event.Before = map[string]interface{}{"email": user.Email}
+			event.After = map[string]interface{}{"email": user.PendingEmail}
+			return h.Audit.RecordTx(tx, event)
+		case email == user.Email && user.VerifiedAt == nil:
+			_, err = tx.Model(&user).
+				Set("verified_at = now()").
+				WherePK().
+				Update()
+			return err
+		case email == user.Email:
+			return nil
+		}
+		return errInvalidVerificationLink
+	})
+	switch err {
+	case nil:
+		utils.RespondWithSuccess(w, http.StatusOK, "Email verified successfully", "message")
+	case errInvalidVerificationLink:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errEmailInUse:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	}
+}
+
+// ResendVerificationEmail send another verification link to an unverified
+// or pending email, at most once per VERIFICATION_RESEND_INTERVAL; the
+// response is the same whether or not a link was sent
+func (h *Handler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload struct{ Email string }
+	json.NewDecoder(r.Body).Decode(&payload)
+	payload.Email = strings.TrimSpace(payload.Email)
+	if err := utils.ValidateEmail(payload.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	interval := TokenLifetime("VERIFICATION_RESEND_INTERVAL", time.Minute)
+	var user User
+	res, err := h.Db.Model(&user).
+		Set("verification_sent_at = now()").
+		Where(
+			"(email = ?0 AND verified_at IS NULL) OR pending_email = ?0",
+			payload.Email,
+		).
+		Where(
+			"verification_sent_at IS NULL OR verification_sent_at < ?",
+			time.Now().Add(-interval),
+		).
+		Returning("id").
+		Update()
+	if err != nil {
+		log.Printf("Could not resend verification email: %v", err)
+	} else if res.RowsAffected() > 0 {
+		h.sendVerificationMail(&user, payload.Email)
+	}
+	utils.RespondWithSuccess(
+		w, http.StatusOK,
+		"If the email is awaiting verification, a verification link has been sent",
+		"message",
+	)
+}
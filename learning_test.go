@@ -0,0 +1,303 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestLearningPaths(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedStep struct{ ResourceId int64 }
+	type ExpectedProgress struct {
+		TotalSteps     int
+		CompletedSteps int
+		Progress       int
+		NextStep       *ExpectedStep
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	learner, learnerToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	thirdUser, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	var resources []Resource
+	for i := 0; i < 3; i++ {
+		resources = append(resources, addTestResource(t, map[string]interface{}{
+			"userId":  owner.Id,
+			"title":   fmt.Sprintf("Lesson %v", i),
+			"type":    "textual",
+			"link":    fmt.Sprintf("https://localhost.textual/lessons/%v.pdf", i),
+			"privacy": "public",
+		}))
+	}
+
+	Request(testServer.URL, t).
+		Post("/api/v1/collection").
+		Set("authorization", ownerToken).
+		Send(`{"name": "Go from scratch", "privacy": "public", "learningPath": true}`).
+		Expect(201).
+		End()
+	var path Collection
+	if err := app.Db.Model(&path).Where("name = ?", "Go from scratch").Select(); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, resource := range resources {
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/collection/add/%v", path.Id)).
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(`{"ResourceId": %v, "section": "Basics"}`, resource.Id)).
+			Expect(200).
+			End()
+	}
+
+	testCollection := dummyData["collection1"].(map[string]interface{})
+	testCollection["userId"] = owner.Id
+	collection := addTestCollection(t, testCollection)
+
+	progressURI := fmt.Sprintf("/api/v1/collection/%v/progress", path.Id)
+	stepURI := func(resource Resource) string {
+		return fmt.Sprintf("%v/%v", progressURI, resource.Id)
+	}
+
+	t.Run("can only enroll in learning paths", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/enroll").
+			Set("authorization", ownerToken).
+			Send(fmt.Sprintf(`{"collectionId": %v}`, collection.Id)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"This collection is not a learning path"}`).
+			End()
+	})
+
+	t.Run("can enroll in a learning path", func(t *testing.T) {
+		for _, token := range []string{learnerToken, thirdUserToken} {
+			Request(testServer.URL, t).
+				Post("/api/v1/collection/enroll").
+				Set("authorization", token).
+				Send(fmt.Sprintf(`{"collectionId": %v}`, path.Id)).
+				Expect(200).
+				Expect("Content-Type", "application/json").
+				Expect(`{"message":"Enrolled in learning path"}`).
+				End()
+		}
+
+		Request(testServer.URL, t).
+			Post("/api/v1/collection/enroll").
+			Set("authorization", learnerToken).
+			Send(fmt.Sprintf(`{"collectionId": %v}`, path.Id)).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are already enrolled in this learning path"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get(progressURI).
+			Set("authorization", learnerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedProgress{3, 0, 0, &ExpectedStep{resources[0].Id}}).
+			End()
+	})
+
+	t.Run("cannot see progress without enrolling", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(progressURI).
+			Set("authorization", ownerToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not enrolled in this learning path"}`).
+			End()
+	})
+
+	t.Run("cannot complete a step with an invalid payload", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(stepURI(resources[0])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": "yes"}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Completed must be true or false"}`).
+			End()
+	})
+
+	t.Run("continues after the step completed last", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(stepURI(resources[1])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": true}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedProgress{3, 1, 33, &ExpectedStep{resources[2].Id}}).
+			End()
+
+		Request(testServer.URL, t).
+			Put(stepURI(resources[2])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": true}`).
+			Expect(200).
+			Expect(ExpectedProgress{3, 2, 66, &ExpectedStep{resources[0].Id}}).
+			End()
+
+		Request(testServer.URL, t).
+			Put(stepURI(resources[0])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": true}`).
+			Expect(200).
+			Expect(ExpectedProgress{3, 3, 100, nil}).
+			End()
+
+		Request(testServer.URL, t).
+			Put(stepURI(resources[0])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": false}`).
+			Expect(200).
+			Expect(struct {
+				Message string
+				ExpectedProgress
+			}{
+				"Step marked as not completed",
+				ExpectedProgress{3, 2, 66, &ExpectedStep{resources[0].Id}},
+			}).
+			End()
+	})
+
+	t.Run("can list their enrollments with their progress", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/collection/enrollments").
+			Set("authorization", learnerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct {
+				TotalCount  int
+				Enrollments []struct {
+					Id       int64
+					Progress int
+				}
+			}{1, []struct {
+				Id       int64
+				Progress int
+			}{{path.Id, 66}}}).
+			End()
+	})
+
+	t.Run("editors can organise steps in sections", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf(
+				"/api/v1/collection/%v/resource/%v/section", path.Id, resources[2].Id,
+			)).
+			Set("authorization", ownerToken).
+			Send(`{"section": " Concurrency "}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Section updated"}`).
+			End()
+
+		type ExpectedSection struct{ Section string }
+		Request(testServer.URL, t).
+			Get(progressURI).
+			Set("authorization", learnerToken).
+			Expect(200).
+			Expect(struct{ Steps []ExpectedSection }{[]ExpectedSection{
+				{"Basics"}, {"Basics"}, {"Concurrency"},
+			}}).
+			End()
+	})
+
+	t.Run("only the owner can see the stats", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/collection/%v/stats", path.Id)).
+			Set("authorization", learnerToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Only the owner can see the stats of this learning path"}`).
+			End()
+
+		// a step that became private to another user is left out
+		hiddenResource := addTestResource(t, map[string]interface{}{
+			"userId":  thirdUser.Id,
+			"title":   "Lesson 3",
+			"type":    "textual",
+			"link":    "https://localhost.textual/lessons/3.pdf",
+			"privacy": "private",
+		})
+		for _, model := range []interface{}{
+			&ResourceCollection{
+				ResourceId: hiddenResource.Id, CollectionId: path.Id, Position: 4,
+			},
+			&StepCompletion{
+				ResourceId: hiddenResource.Id, CollectionId: path.Id, UserId: learner.Id,
+			},
+		} {
+			if err := app.Db.Insert(model); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		type ExpectedStepStats struct {
+			ResourceId     int64
+			CompletedCount int
+		}
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/collection/%v/stats", path.Id)).
+			Set("authorization", ownerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct {
+				EnrolledCount   int
+				CompletedCount  int
+				AverageProgress int
+				TotalSteps      int
+				Steps           []ExpectedStepStats
+			}{2, 0, 33, 3, []ExpectedStepStats{
+				{resources[0].Id, 0}, {resources[1].Id, 1}, {resources[2].Id, 1},
+			}}).
+			End()
+	})
+
+	t.Run("completions go with the steps removed from the path", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/collection/%v/resource/%v", path.Id, resources[1].Id)).
+			Set("authorization", ownerToken).
+			Expect(200).
+			End()
+
+		count, err := app.Db.Model(&StepCompletion{}).
+			Where("collection_id = ? AND resource_id = ?", path.Id, resources[1].Id).
+			Count()
+		if err != nil || count != 0 {
+			t.Fatalf("Expected no completions of the removed step; Got %v %v", count, err)
+		}
+	})
+
+	t.Run("can leave a learning path", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/collection/enroll/%v", path.Id)).
+			Set("authorization", learnerToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Left learning path"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(stepURI(resources[0])).
+			Set("authorization", learnerToken).
+			Send(`{"completed": true}`).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not enrolled in this learning path"}`).
+			End()
+	})
+}
@@ -0,0 +1,135 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+// findMentions get the mentions of a comment, or of the description of a
+// resource when commentId is 0
+func findMentions(t *testing.T, resourceId, commentId int64) []Mention {
+	var mentions []Mention
+	query := app.Db.Model(&mentions).Where("resource_id = ?", resourceId)
+	if commentId == 0 {
+		query = query.Where("comment_id IS NULL")
+	} else {
+		query = query.Where("comment_id = ?", commentId)
+	}
+	if err := query.Order("offset ASC").Select(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return mentions
+}
+
+func TestMentions(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	author, authorToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	mentioned, mentionedToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = owner.Id
+	resource := addTestResource(t, testResource)
+
+	privateResource := dummyData["privateResource"].(map[string]interface{})
+	privateResource["userId"] = owner.Id
+	hidden := addTestResource(t, privateResource)
+
+	var comment Comment
+
+	t.Run("mentions in a comment are stored with their offsets", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/comment").
+			Set("authorization", authorToken).
+			Send(fmt.Sprintf(
+				`{"text": "Thanks @thirdUser! cc email@test @nobody", "resourceId": %v}`,
+				resource.Id,
+			)).
+			Expect(200).
+			End()
+
+		if err := app.Db.Model(&comment).Where("user_id = ?", author.Id).Select(); err != nil {
+			t.Fatal(err.Error())
+		}
+		mentions := findMentions(t, resource.Id, comment.Id)
+		if len(mentions) != 1 || mentions[0].UserId != mentioned.Id {
+			t.Fatalf("Expected only the mention of %v; Got %v", mentioned.Username, mentions)
+		}
+		if mentions[0].Offset != 7 || mentions[0].Length != 10 {
+			t.Fatalf("Expected the mention at 7 with length 10; Got %+v", mentions[0])
+		}
+	})
+
+	t.Run("mentioned users are notified", func(t *testing.T) {
+		count, err := app.Db.Model(&Notification{}).
+			Where("user_id = ? AND type = ?", mentioned.Id, MentionNotification).
+			Count()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if count != 1 {
+			t.Fatalf("Expected 1 mention notification; Got %v", count)
+		}
+	})
+
+	t.Run("editing a comment replaces its mentions", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/comment/%v", comment.Id)).
+			Set("authorization", authorToken).
+			Send(`{"text": "@test and @thirdUser, thanks"}`).
+			Expect(200).
+			End()
+
+		mentions := findMentions(t, resource.Id, comment.Id)
+		if len(mentions) != 2 || mentions[0].UserId != owner.Id || mentions[1].Offset != 10 {
+			t.Fatalf("Expected the mentions of the edited text; Got %v", mentions)
+		}
+	})
+
+	t.Run("users who cannot see the resource are not mentioned", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/resource/%v", hidden.Id)).
+			Set("authorization", ownerToken).
+			Send(`{"description": "Notes for @thirdUser"}`).
+			Expect(200).
+			End()
+
+		if mentions := findMentions(t, hidden.Id, 0); len(mentions) != 0 {
+			t.Fatalf("Expected no mentions on a private resource; Got %v", mentions)
+		}
+	})
+
+	t.Run("users blocking the author are not mentioned", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection/block").
+			Set("authorization", mentionedToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, owner.Id)).
+			Expect(200).
+			End()
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/resource/%v", resource.Id)).
+			Set("authorization", ownerToken).
+			Send(`{"description": "Thanks @thirdUser and @anotherUser"}`).
+			Expect(200).
+			End()
+
+		mentions := findMentions(t, resource.Id, 0)
+		if len(mentions) != 1 || mentions[0].UserId != author.Id {
+			t.Fatalf("Expected only the mention of %v; Got %v", author.Username, mentions)
+		}
+	})
+}
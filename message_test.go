@@ -0,0 +1,135 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestMessages(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedMessage struct {
+		SenderId    int64
+		RecipientId int64
+		Content     string
+	}
+	type ExpectedResponse struct {
+		Messages   []ExpectedMessage
+		TotalCount int
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	addTestConnection(t, map[string]interface{}{
+		"initiatorId": anotherUser.Id,
+		"recipientId": user.Id,
+	})
+
+	t.Run("cannot send an empty message", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/message").
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"recipientId": %v, "content": " "}`, anotherUser.Id)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"message Content is required, it cannot be empty"}`).
+			End()
+	})
+
+	t.Run("cannot message a user who is not connected", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/message").
+			Set("authorization", thirdUserToken).
+			Send(fmt.Sprintf(`{"recipientId": %v, "content": "Hello"}`, user.Id)).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You can only message users you are connected with"}`).
+			End()
+	})
+
+	t.Run("can message a connected user", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/message").
+			Set("authorization", userToken).
+			Send(fmt.Sprintf(`{"recipientId": %v, "content": "Hello",
+			"id": 999, "createdAt": "2000-01-01T00:00:00Z"}`, anotherUser.Id)).
+			Expect(201).
+			Expect("Content-Type", "application/json").
+			End()
+
+		var message Message
+		if err := app.Db.Model(&message).Where("sender_id = ?", user.Id).Select(); err != nil {
+			t.Fatal(err.Error())
+		}
+		if message.Id == 999 || message.CreatedAt.Year() == 2000 {
+			t.Fatalf("Expected the id and creation time to be set by the server; Got %+v", message)
+		}
+	})
+
+	t.Run("recipient can count unread messages", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/message/unread").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"unreadCount":1}`).
+			End()
+	})
+
+	t.Run("recipient can get messages of a conversation", func(t *testing.T) {
+		expectedResponse := ExpectedResponse{
+			[]ExpectedMessage{{user.Id, anotherUser.Id, "Hello"}},
+			1,
+		}
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/message/%v", user.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+	})
+
+	t.Run("recipient can mark messages as read", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/message/read").
+			Set("authorization", anotherUserToken).
+			Send(fmt.Sprintf(`{"userId": %v}`, user.Id)).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Messages marked as read","readCount":1}`).
+			End()
+	})
+	t.Run("messages survive an unfollow", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/connection/%v", user.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			End()
+
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/message/%v", anotherUser.Id)).
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedResponse{
+				[]ExpectedMessage{{user.Id, anotherUser.Id, "Hello"}},
+				1,
+			}).
+			End()
+	})
+}
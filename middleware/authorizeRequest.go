@@ -1,16 +1,62 @@
 package middleware
 
 import (
+	. "WeKnow_api/model"
 	utils "WeKnow_api/utilities"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-pg/pg"
 	"github.com/gorilla/context"
 )
 
+var (
+	errRevokedToken = errors.New("Token has been revoked")
+	errInvalidToken = errors.New("Invalid authorization token")
+)
+
+// checkTokenUser check that the user of a token can still use it; the token
+// must be issued after the user last changed their password or logged out of
+// all devices, and the account must not be suspended or banned. Tokens issued
+// for another purpose, like verifying an email, are rejected. The role in the
+// claims is replaced by the current role of the user.
+func (mw *Middleware) checkTokenUser(claims jwt.MapClaims) (int, error) {
+	userId, _ := claims["userId"].(float64)
+	issuedAt, hasIssuedAt := claims["iat"].(float64)
+	if _, hasPurpose := claims["purpose"]; hasPurpose || !hasIssuedAt {
+		return http.StatusUnauthorized, errInvalidToken
+	}
+	user := User{Id: int64(userId)}
+	err := mw.Db.Model(&user).
+		Column(
+			"password_changed_at", "logged_out_at",
+			"role", "suspended_until", "banned_at",
+		).
+		WherePK().
+		Select()
+	if err == pg.ErrNoRows {
+		return http.StatusUnauthorized, errRevokedToken
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, cutoff := range []*time.Time{user.PasswordChangedAt, user.LoggedOutAt} {
+		// iat has a precision of seconds
+		if cutoff != nil && int64(issuedAt) < cutoff.Unix() {
+			return http.StatusUnauthorized, errRevokedToken
+		}
+	}
+	if err := user.Restriction(); err != nil {
+		return http.StatusForbidden, err
+	}
+	claims["role"] = user.Role
+	return http.StatusOK, nil
+}
+
 // AuthorizeRequest parse, verify and decode token
 func (mw *Middleware) AuthorizeRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -32,8 +78,15 @@ func (mw *Middleware) AuthorizeRequest(next http.Handler) http.Handler {
 				if error != nil {
 					utils.RespondWithError(w, http.StatusUnauthorized, error.Error())
 				} else if token.Valid {
-					context.Set(r, "decoded", token.Claims)
-					next.ServeHTTP(w, r)
+					status, err := mw.checkTokenUser(token.Claims.(jwt.MapClaims))
+					if status == http.StatusInternalServerError {
+						utils.RespondWithError(w, status, "Something went wrong")
+					} else if err != nil {
+						utils.RespondWithError(w, status, err.Error())
+					} else {
+						context.Set(r, "decoded", token.Claims)
+						next.ServeHTTP(w, r)
+					}
 				}
 			} else {
 				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid authorization token")
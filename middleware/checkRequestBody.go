@@ -6,8 +6,19 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
+// RecommendResourceRoute name of the route to recommend a resource
+const RecommendResourceRoute = "recommendResource"
+
+// routesWithoutPayload names of the POST and PUT routes whose action is
+// given by their path, so they take no request body
+var routesWithoutPayload = map[string]bool{
+	RecommendResourceRoute: true,
+}
+
 // CheckRequestBody check if request body is empty for post and put requests
 func (mw *Middleware) CheckRequestBody(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -15,6 +26,10 @@ func (mw *Middleware) CheckRequestBody(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		if route := mux.CurrentRoute(r); route != nil && routesWithoutPayload[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
 		bodyBytes, _ := ioutil.ReadAll(r.Body)
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 		if len(bodyBytes) == 0 {
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	utils "WeKnow_api/utilities"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/context"
+)
+
+// RequireRole allow only users with one of roles
+func (mw *Middleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := context.Get(r, "decoded").(jwt.MapClaims)["role"].(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			utils.RespondWithError(w, http.StatusForbidden,
+				"You are not allowed to perform this action")
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	. "WeKnow_api/model"
+	utils "WeKnow_api/utilities"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/context"
+)
+
+// RequireVerifiedEmail allow only users with a verified email
+func (mw *Middleware) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId := context.Get(r, "decoded").(jwt.MapClaims)["userId"].(float64)
+		verified, err := mw.Db.Model(&User{}).
+			Where("id = ? AND verified_at IS NOT NULL", int64(userId)).
+			Exists()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		if !verified {
+			utils.RespondWithError(w, http.StatusForbidden,
+				"Please verify your email address first")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
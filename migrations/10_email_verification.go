@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding email verification...")
+		// accounts created before verification existed count as verified
+		_, err := db.Exec(`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS verified_at timestamptz,
+		ADD COLUMN IF NOT EXISTS pending_email text,
+		ADD COLUMN IF NOT EXISTS verification_sent_at timestamptz;
+		UPDATE users SET verified_at = COALESCE(created_at, now())
+		WHERE verified_at IS NULL`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping email verification...")
+		_, err := db.Exec(`ALTER TABLE users
+		DROP COLUMN IF EXISTS verified_at,
+		DROP COLUMN IF EXISTS pending_email,
+		DROP COLUMN IF EXISTS verification_sent_at`)
+		return err
+	})
+}
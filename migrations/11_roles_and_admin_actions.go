@@ -0,0 +1,33 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding user roles, suspensions and admin actions...")
+		_, err := db.Exec(`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user',
+		ADD COLUMN IF NOT EXISTS suspended_until timestamptz,
+		ADD COLUMN IF NOT EXISTS banned_at timestamptz`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &AdminAction{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping user roles, suspensions and admin actions...")
+		if err := dropTables(db, &AdminAction{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE users
+		DROP COLUMN IF EXISTS role,
+		DROP COLUMN IF EXISTS suspended_until,
+		DROP COLUMN IF EXISTS banned_at`)
+		return err
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding reports and hidden content...")
+		if err := createTables(db, &Report{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE resources
+		ADD COLUMN IF NOT EXISTS hidden_at timestamptz;
+		ALTER TABLE comments
+		ADD COLUMN IF NOT EXISTS hidden_at timestamptz;
+		ALTER TABLE collections
+		ADD COLUMN IF NOT EXISTS hidden_at timestamptz;
+		ALTER TABLE notifications
+		ADD COLUMN IF NOT EXISTS report_id bigint`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping reports and hidden content...")
+		_, err := db.Exec(`ALTER TABLE notifications
+		DROP COLUMN IF EXISTS report_id;
+		ALTER TABLE resources DROP COLUMN IF EXISTS hidden_at;
+		ALTER TABLE comments DROP COLUMN IF EXISTS hidden_at;
+		ALTER TABLE collections DROP COLUMN IF EXISTS hidden_at`)
+		if err != nil {
+			return err
+		}
+		return dropTables(db, &Report{})
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding audit events...")
+		if err := createTables(db, &AuditEvent{}); err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile("migrations/audit_sql.txt")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(string(content))
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping audit events...")
+		if err := dropTables(db, &AuditEvent{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`DROP FUNCTION IF EXISTS audit_events_append_only()`)
+		return err
+	})
+}
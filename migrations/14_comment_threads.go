@@ -0,0 +1,37 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding comment threads and edits...")
+		_, err := db.Exec(`ALTER TABLE comments
+		ADD COLUMN IF NOT EXISTS parent_id bigint
+			REFERENCES comments (id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS depth bigint NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS edited_at timestamptz,
+		ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+		CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id)`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &CommentEdit{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping comment threads and edits...")
+		if err := dropTables(db, &CommentEdit{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE comments
+		DROP COLUMN IF EXISTS parent_id,
+		DROP COLUMN IF EXISTS depth,
+		DROP COLUMN IF EXISTS edited_at,
+		DROP COLUMN IF EXISTS deleted_at`)
+		return err
+	})
+}
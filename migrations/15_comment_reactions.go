@@ -0,0 +1,28 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding comment reactions...")
+		_, err := db.Exec(`ALTER TABLE comments
+		ADD COLUMN IF NOT EXISTS reactions jsonb NOT NULL DEFAULT '{}'`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &CommentReaction{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping comment reactions...")
+		if err := dropTables(db, &CommentReaction{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE comments DROP COLUMN IF EXISTS reactions`)
+		return err
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding mentions and resource descriptions...")
+		_, err := db.Exec(`ALTER TABLE resources
+		ADD COLUMN IF NOT EXISTS description text`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &Mention{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping mentions and resource descriptions...")
+		if err := dropTables(db, &Mention{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE resources DROP COLUMN IF EXISTS description`)
+		return err
+	})
+}
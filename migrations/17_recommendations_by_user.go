@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("indexing recommendations by user...")
+		_, err := db.Exec(`CREATE INDEX IF NOT EXISTS recommendations_user_id_idx
+		ON recommendations (user_id, created_at)`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping recommendations index...")
+		_, err := db.Exec(`DROP INDEX IF EXISTS recommendations_user_id_idx`)
+		return err
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding resource ratings...")
+		_, err := db.Exec(`ALTER TABLE resources
+		ADD COLUMN IF NOT EXISTS rating_count bigint NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS rating_average double precision NOT NULL DEFAULT 0`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &Rating{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping resource ratings...")
+		if err := dropTables(db, &Rating{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE resources
+		DROP COLUMN IF EXISTS rating_count,
+		DROP COLUMN IF EXISTS rating_average`)
+		return err
+	})
+}
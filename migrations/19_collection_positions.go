@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding positions to collection resources...")
+		_, err := db.Exec(`ALTER TABLE resource_collections
+		ADD COLUMN IF NOT EXISTS position bigint NOT NULL DEFAULT 0;
+		UPDATE resource_collections AS rc SET position = numbered.position
+		FROM (SELECT resource_id, collection_id, row_number() OVER (
+			PARTITION BY collection_id ORDER BY resource_id) AS position
+		FROM resource_collections) AS numbered
+		WHERE rc.resource_id = numbered.resource_id
+		AND rc.collection_id = numbered.collection_id AND rc.position = 0`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping positions of collection resources...")
+		_, err := db.Exec(`ALTER TABLE resource_collections
+		DROP COLUMN IF EXISTS position`)
+		return err
+	})
+}
@@ -12,8 +12,8 @@ import (
 func createSchema(db migrations.DB) error {
 	for _, model := range []interface{}{
 		&User{},
-		&Message{},
 		&Connection{},
+		&Message{},
 		&Resource{},
 		&Comment{},
 		&Collection{},
@@ -45,8 +45,8 @@ func createSchema(db migrations.DB) error {
 func dropSchema(db migrations.DB) error {
 	for _, model := range []interface{}{
 		&User{},
-		&Message{},
 		&Connection{},
+		&Message{},
 		&Resource{},
 		&Comment{},
 		&Collection{},
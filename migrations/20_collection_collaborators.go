@@ -0,0 +1,19 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding collection collaborators...")
+		return createTables(db, &CollectionCollaborator{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping collection collaborators...")
+		return dropTables(db, &CollectionCollaborator{})
+	})
+}
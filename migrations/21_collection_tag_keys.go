@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding foreign keys to collection tags...")
+		_, err := db.Exec(`DELETE FROM collection_tags AS ct
+		WHERE NOT EXISTS(SELECT * FROM collections WHERE id = ct.collection_id)
+		OR NOT EXISTS(SELECT * FROM tags WHERE id = ct.tag_id);
+		ALTER TABLE collection_tags
+		DROP CONSTRAINT IF EXISTS collection_tags_collection_id_fkey,
+		DROP CONSTRAINT IF EXISTS collection_tags_tag_id_fkey,
+		ADD CONSTRAINT collection_tags_collection_id_fkey FOREIGN KEY(collection_id)
+			REFERENCES collections (id) ON DELETE CASCADE,
+		ADD CONSTRAINT collection_tags_tag_id_fkey FOREIGN KEY(tag_id)
+			REFERENCES tags (id) ON DELETE CASCADE;
+		CREATE INDEX IF NOT EXISTS collection_tags_collection_id_idx
+		ON collection_tags (collection_id)`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping foreign keys of collection tags...")
+		_, err := db.Exec(`DROP INDEX IF EXISTS collection_tags_collection_id_idx;
+		ALTER TABLE collection_tags
+		DROP CONSTRAINT IF EXISTS collection_tags_collection_id_fkey,
+		DROP CONSTRAINT IF EXISTS collection_tags_tag_id_fkey`)
+		return err
+	})
+}
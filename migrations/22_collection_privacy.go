@@ -0,0 +1,37 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding privacy and followers to collections...")
+		_, err := db.Exec(`ALTER TABLE collections
+		ADD COLUMN IF NOT EXISTS privacy text NOT NULL DEFAULT 'private',
+		ADD COLUMN IF NOT EXISTS share_token text UNIQUE,
+		ADD COLUMN IF NOT EXISTS forked_from_id bigint
+			REFERENCES collections (id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS collections_public_idx
+		ON collections (created_at) WHERE privacy = 'public'`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &CollectionFollower{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping privacy and followers of collections...")
+		if err := dropTables(db, &CollectionFollower{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`DROP INDEX IF EXISTS collections_public_idx;
+		ALTER TABLE collections
+		DROP COLUMN IF EXISTS forked_from_id,
+		DROP COLUMN IF EXISTS share_token,
+		DROP COLUMN IF EXISTS privacy`)
+		return err
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding learning paths...")
+		_, err := db.Exec(`ALTER TABLE collections
+		ADD COLUMN IF NOT EXISTS learning_path boolean NOT NULL DEFAULT false;
+		ALTER TABLE resource_collections
+		ADD COLUMN IF NOT EXISTS section text`)
+		if err != nil {
+			return err
+		}
+		if err := createTables(db, &Enrollment{}, &StepCompletion{}); err != nil {
+			return err
+		}
+		// completions go with the steps removed from a learning path
+		_, err = db.Exec(`ALTER TABLE step_completions
+		DROP CONSTRAINT IF EXISTS step_completions_step_fkey,
+		ADD CONSTRAINT step_completions_step_fkey
+			FOREIGN KEY(resource_id, collection_id)
+			REFERENCES resource_collections (resource_id, collection_id)
+			ON DELETE CASCADE`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping learning paths...")
+		if err := dropTables(db, &StepCompletion{}, &Enrollment{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE resource_collections
+		DROP COLUMN IF EXISTS section;
+		ALTER TABLE collections
+		DROP COLUMN IF EXISTS learning_path`)
+		return err
+	})
+}
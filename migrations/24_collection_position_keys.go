@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("keeping positions of collection resources distinct...")
+		// deleted resources left gaps that later additions may have filled
+		// with duplicates
+		_, err := db.Exec(`UPDATE resource_collections AS rc
+		SET position = numbered.position
+		FROM (SELECT resource_id, collection_id, row_number() OVER (
+			PARTITION BY collection_id ORDER BY position, resource_id) AS position
+			FROM resource_collections) AS numbered
+		WHERE rc.resource_id = numbered.resource_id
+		AND rc.collection_id = numbered.collection_id
+		AND rc.position != numbered.position`)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile("migrations/collection_sql.txt")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(string(content))
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping position keys of collection resources...")
+		_, err := db.Exec(`DROP TRIGGER IF EXISTS resource_collections_compact
+			ON resource_collections;
+		DROP FUNCTION IF EXISTS resource_collections_compact();
+		ALTER TABLE resource_collections
+		DROP CONSTRAINT IF EXISTS resource_collections_position_key`)
+		return err
+	})
+}
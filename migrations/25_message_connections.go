@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("keeping messages of removed connections...")
+		_, err := db.Exec(`ALTER TABLE messages
+		ALTER COLUMN connection_id DROP NOT NULL,
+		DROP CONSTRAINT IF EXISTS messages_connection_id_fkey,
+		ADD CONSTRAINT messages_connection_id_fkey FOREIGN KEY(connection_id)
+			REFERENCES connections (id) ON DELETE SET NULL`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("deleting messages with removed connections...")
+		_, err := db.Exec(`DELETE FROM messages WHERE connection_id IS NULL;
+		ALTER TABLE messages
+		ALTER COLUMN connection_id SET NOT NULL,
+		DROP CONSTRAINT IF EXISTS messages_connection_id_fkey,
+		ADD CONSTRAINT messages_connection_id_fkey FOREIGN KEY(connection_id)
+			REFERENCES connections (id) ON DELETE CASCADE`)
+		return err
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("keeping one unresolved report per reporter...")
+		// concurrent reports may have got past the check of the handler;
+		// the earliest report stays open
+		_, err := db.Exec(`UPDATE reports AS report
+		SET status = 'resolved', resolution = 'dismiss', resolved_at = now()
+		WHERE report.status != 'resolved' AND EXISTS(
+			SELECT * FROM reports AS earlier
+			WHERE earlier.reporter_id = report.reporter_id
+			AND earlier.target_type = report.target_type
+			AND earlier.target_id = report.target_id
+			AND earlier.status != 'resolved' AND earlier.id < report.id)`)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile("migrations/report_sql.txt")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(string(content))
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping the unresolved report key...")
+		_, err := db.Exec(`DROP INDEX IF EXISTS reports_reporter_id_target_idx`)
+		return err
+	})
+}
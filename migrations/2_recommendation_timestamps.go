@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding created_at to recommendations...")
+		_, err := db.Exec(`ALTER TABLE recommendations
+		ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now()`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping created_at from recommendations...")
+		_, err := db.Exec(`ALTER TABLE recommendations
+		DROP COLUMN IF EXISTS created_at`)
+		return err
+	})
+}
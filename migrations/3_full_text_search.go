@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding full text search columns...")
+		content, err := ioutil.ReadFile("migrations/search_sql.txt")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(string(content))
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping full text search columns...")
+		for _, table := range []string{"resources", "tags", "collections", "users"} {
+			_, err := db.Exec(fmt.Sprintf(
+				`DROP TRIGGER IF EXISTS %[1]s_search_vector_update ON %[1]s;
+				ALTER TABLE %[1]s DROP COLUMN IF EXISTS search_vector`,
+				table,
+			))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
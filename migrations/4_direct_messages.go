@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding message participants...")
+		_, err := db.Exec(`ALTER TABLE messages
+		DROP COLUMN IF EXISTS connection,
+		ADD COLUMN IF NOT EXISTS connection_id bigint NOT NULL
+			REFERENCES connections (id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS sender_id bigint NOT NULL
+			REFERENCES users (id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS recipient_id bigint NOT NULL
+			REFERENCES users (id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS read_at timestamptz;
+		ALTER TABLE messages ALTER COLUMN content SET NOT NULL;
+		CREATE INDEX IF NOT EXISTS messages_sender_id_recipient_id_idx
+		ON messages (sender_id, recipient_id)`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping message participants...")
+		_, err := db.Exec(`DROP INDEX IF EXISTS messages_sender_id_recipient_id_idx;
+		ALTER TABLE messages
+		DROP COLUMN IF EXISTS connection_id,
+		DROP COLUMN IF EXISTS sender_id,
+		DROP COLUMN IF EXISTS recipient_id,
+		DROP COLUMN IF EXISTS read_at,
+		ADD COLUMN IF NOT EXISTS connection jsonb`)
+		return err
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("creating notification tables...")
+		return createTables(db, &Notification{}, &NotificationSetting{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping notification tables...")
+		return dropTables(db, &Notification{}, &NotificationSetting{})
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("creating block and mute tables...")
+		return createTables(db, &Block{}, &Mute{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping block and mute tables...")
+		return dropTables(db, &Block{}, &Mute{})
+	})
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding private accounts and connection status...")
+		_, err := db.Exec(`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS private boolean NOT NULL DEFAULT false;
+		ALTER TABLE connections
+		ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'accepted'`)
+		return err
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping private accounts and connection status...")
+		_, err := db.Exec(`ALTER TABLE users DROP COLUMN IF EXISTS private;
+		ALTER TABLE connections DROP COLUMN IF EXISTS status`)
+		return err
+	})
+}
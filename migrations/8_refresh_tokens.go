@@ -0,0 +1,31 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("adding refresh tokens and token revocation...")
+		_, err := db.Exec(`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS password_changed_at timestamptz,
+		ADD COLUMN IF NOT EXISTS logged_out_at timestamptz`)
+		if err != nil {
+			return err
+		}
+		return createTables(db, &RefreshToken{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping refresh tokens and token revocation...")
+		if err := dropTables(db, &RefreshToken{}); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE users
+		DROP COLUMN IF EXISTS password_changed_at,
+		DROP COLUMN IF EXISTS logged_out_at`)
+		return err
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	. "WeKnow_api/model"
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.Register(func(db migrations.DB) error {
+		fmt.Println("creating password reset token table...")
+		return createTables(db, &PasswordResetToken{})
+
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping password reset token table...")
+		return dropTables(db, &PasswordResetToken{})
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"WeKnow_api/model"
 	"WeKnow_api/utilities"
 
 	"github.com/go-pg/migrations"
@@ -18,6 +20,7 @@ const usageText = `This program runs command on the db. Supported commands are:
   - reset - reverts all migrations.
   - version - prints current db version.
   - set_version [version] - sets db version without running migrations.
+  - set_role [email] [role] - sets the role (user, moderator or admin) of a user.
 Usage:
   go run *.go <command> [args]
 `
@@ -31,6 +34,11 @@ func main() {
 	config := utilities.GetDatabaseCredentials()
 	db := utilities.Connect(config)
 
+	if flag.Arg(0) == "set_role" {
+		setRole(db, flag.Arg(1), flag.Arg(2))
+		return
+	}
+
 	var oldVersion, newVersion int64
 	err := db.RunInTransaction(func(tx *pg.Tx) (err error) {
 		oldVersion, newVersion, err = migrations.Run(db, flag.Args()...)
@@ -46,6 +54,24 @@ func main() {
 	}
 }
 
+// setRole set the role of a user, e.g to create the first admin
+func setRole(db *pg.DB, email, role string) {
+	if model.RoleRank(role) < 0 {
+		exitf("role must be one of %s", strings.Join(model.Roles, ", "))
+	}
+	res, err := db.Model(&model.User{}).
+		Set("role = ?", role).
+		Where("email = ?", email).
+		Update()
+	if err != nil {
+		exitf(err.Error())
+	}
+	if res.RowsAffected() == 0 {
+		exitf("no user with email %s", email)
+	}
+	fmt.Printf("%s is now %s\n", email, role)
+}
+
 func usage() {
 	fmt.Printf(usageText)
 	flag.PrintDefaults()
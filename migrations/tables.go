@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/go-pg/migrations"
+	"github.com/go-pg/pg/orm"
+)
+
+// createTables create the tables of models added after the initial setup
+func createTables(db migrations.DB, models ...interface{}) error {
+	for _, model := range models {
+		if _, err := orm.CreateTable(
+			db,
+			model,
+			&orm.CreateTableOptions{IfNotExists: true, FKConstraints: true},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTables drop the tables of models added after the initial setup
+func dropTables(db migrations.DB, models ...interface{}) error {
+	for _, model := range models {
+		if _, err := orm.DropTable(
+			db,
+			model,
+			&orm.DropTableOptions{IfExists: true, Cascade: true},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
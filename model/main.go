@@ -11,8 +11,8 @@ import (
 func CreateSchema(db *pg.DB) error {
 	for _, model := range []interface{}{
 		&User{},
-		&Message{},
 		&Connection{},
+		&Message{},
 		&Resource{},
 		&Comment{},
 		&Collection{},
@@ -22,6 +22,23 @@ func CreateSchema(db *pg.DB) error {
 		&UserConnection{},
 		&Recommendation{},
 		&ResourceCollection{},
+		&Notification{},
+		&NotificationSetting{},
+		&Block{},
+		&Mute{},
+		&RefreshToken{},
+		&PasswordResetToken{},
+		&AdminAction{},
+		&Report{},
+		&AuditEvent{},
+		&CommentEdit{},
+		&CommentReaction{},
+		&Mention{},
+		&Rating{},
+		&CollectionCollaborator{},
+		&CollectionFollower{},
+		&Enrollment{},
+		&StepCompletion{},
 	} {
 		if err := db.CreateTable(
 			model,
@@ -30,12 +47,20 @@ func CreateSchema(db *pg.DB) error {
 			return err
 		}
 	}
-	content, err := ioutil.ReadFile("migrations/sql.txt")
-	if err != nil {
-		return err
-	}
-	if _, err := db.Exec(string(content)); err != nil {
-		return err
+	for _, file := range []string{
+		"migrations/sql.txt",
+		"migrations/search_sql.txt",
+		"migrations/audit_sql.txt",
+		"migrations/collection_sql.txt",
+		"migrations/report_sql.txt",
+	} {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -44,8 +69,8 @@ func CreateSchema(db *pg.DB) error {
 func DropSchema(db *pg.DB) error {
 	for _, model := range []interface{}{
 		&User{},
-		&Message{},
 		&Connection{},
+		&Message{},
 		&Resource{},
 		&Comment{},
 		&Collection{},
@@ -55,6 +80,23 @@ func DropSchema(db *pg.DB) error {
 		&UserConnection{},
 		&Recommendation{},
 		&ResourceCollection{},
+		&Notification{},
+		&NotificationSetting{},
+		&Block{},
+		&Mute{},
+		&RefreshToken{},
+		&PasswordResetToken{},
+		&AdminAction{},
+		&Report{},
+		&AuditEvent{},
+		&CommentEdit{},
+		&CommentReaction{},
+		&Mention{},
+		&Rating{},
+		&CollectionCollaborator{},
+		&CollectionFollower{},
+		&Enrollment{},
+		&StepCompletion{},
 	} {
 		if err := db.DropTable(
 			model,
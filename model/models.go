@@ -1,6 +1,9 @@
 package model
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -41,15 +44,29 @@ type Exception struct {
 }
 
 type User struct {
-	Id          int64         `json:",omitempty"`
-	Username    string        `sql:",unique,notnull" json:",omitempty"`
-	Email       string        `sql:",unique,notnull" json:",omitempty"`
-	Password    string        `json:",omitempty"`
-	PhoneNumber string        `json:",omitempty"`
-	Connections []*Connection `pg:",many2many:user_connections" json:",omitempty"`
-	Comments    []*Comment    `json:",omitempty"`
-	Collections []*Collection `json:",omitempty"`
-	Resources   []*Resource   `json:",omitempty"`
+	tableName struct{} `pg:",discard_unknown_columns"`
+
+	Id          int64      `json:",omitempty"`
+	Username    string     `sql:",unique,notnull" json:",omitempty"`
+	Email       string     `sql:",unique,notnull" json:",omitempty"`
+	Password    string     `json:",omitempty"`
+	PhoneNumber string     `json:",omitempty"`
+	Private     bool       `sql:",notnull,default:false" json:",omitempty"`
+	VerifiedAt  *time.Time `json:",omitempty"`
+	Role        string     `sql:",notnull,default:'user'" json:",omitempty"`
+	// suspended users cannot use their account until SuspendedUntil
+	SuspendedUntil *time.Time `json:",omitempty"`
+	BannedAt       *time.Time `json:",omitempty"`
+	// the new email of the user until it is verified
+	PendingEmail       string     `json:",omitempty"`
+	VerificationSentAt *time.Time `json:"-"`
+	// tokens issued before either time are rejected
+	PasswordChangedAt *time.Time    `json:"-"`
+	LoggedOutAt       *time.Time    `json:"-"`
+	Connections       []*Connection `pg:",many2many:user_connections" json:",omitempty"`
+	Comments          []*Comment    `json:",omitempty"`
+	Collections       []*Collection `json:",omitempty"`
+	Resources         []*Resource   `json:",omitempty"`
 	BaseModel
 }
 
@@ -93,15 +110,42 @@ func (u *User) CompareHashAndPassword(password string) bool {
 	return err == nil
 }
 
-// GenerateToken generate authorization token
+// Restriction get why the user cannot use their account, if they cannot
+func (u User) Restriction() error {
+	if u.BannedAt != nil {
+		return errors.New("Your account has been banned")
+	}
+	if u.SuspendedUntil != nil && u.SuspendedUntil.After(time.Now()) {
+		return fmt.Errorf(
+			"Your account is suspended until %s",
+			u.SuspendedUntil.Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+// TokenLifetime read a positive token lifetime from an env var or use
+// fallback
+func TokenLifetime(key string, fallback time.Duration) time.Duration {
+	if lifetime, err := time.ParseDuration(os.Getenv(key)); err == nil && lifetime > 0 {
+		return lifetime
+	}
+	return fallback
+}
+
+// GenerateToken generate short-lived authorization token
 func (u User) GenerateToken() (string, error) {
+	now := time.Now()
+	lifetime := TokenLifetime("ACCESS_TOKEN_TTL", 15*time.Minute)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId":      u.Id,
 		"username":    u.Username,
 		"email":       u.Email,
 		"phoneNumber": u.PhoneNumber,
+		"role":        u.Role,
 		"iss":         os.Getenv("ISSUER"),
-		"exp":         time.Now().Add(time.Hour * 24).Unix(),
+		"iat":         now.Unix(),
+		"exp":         now.Add(lifetime).Unix(),
 	})
 	HMACSecret := os.Getenv("JWT_SECRET")
 	tokenString, error := token.SignedString([]byte(HMACSecret))
@@ -111,20 +155,100 @@ func (u User) GenerateToken() (string, error) {
 	return tokenString, nil
 }
 
+// verificationKey derive the key of verification tokens from the secret of
+// authorization tokens, so that neither kind passes for the other
+func verificationKey() []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("verifyEmail"))
+	return mac.Sum(nil)
+}
+
+// GenerateVerificationToken generate a signed token that verifies email
+// belongs to the user
+func (u User) GenerateVerificationToken(email string) (string, error) {
+	now := time.Now()
+	lifetime := TokenLifetime("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId":  u.Id,
+		"email":   email,
+		"purpose": "verifyEmail",
+		"aud":     "verifyEmail",
+		"iss":     os.Getenv("ISSUER"),
+		"iat":     now.Unix(),
+		"exp":     now.Add(lifetime).Unix(),
+	})
+	return token.SignedString(verificationKey())
+}
+
+// ParseVerificationToken get the user id and email of a verification token
+func ParseVerificationToken(tokenString string) (int64, string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method")
+		}
+		return verificationKey(), nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userId, _ := claims["userId"].(float64)
+	email, _ := claims["email"].(string)
+	if claims["purpose"] != "verifyEmail" ||
+		!claims.VerifyAudience("verifyEmail", true) ||
+		!claims.VerifyIssuer(os.Getenv("ISSUER"), true) ||
+		userId == 0 || email == "" {
+		return 0, "", fmt.Errorf("Invalid verification token")
+	}
+	return int64(userId), email, nil
+}
+
+const (
+	UserRole      = "user"
+	ModeratorRole = "moderator"
+	AdminRole     = "admin"
+)
+
+// Roles all user roles, from least to most powerful
+var Roles = []string{UserRole, ModeratorRole, AdminRole}
+
+// RoleRank get how powerful a role is; unknown roles rank lowest
+func RoleRank(role string) int {
+	for rank, name := range Roles {
+		if name == role {
+			return rank
+		}
+	}
+	return -1
+}
+
 type Connection struct {
 	Id          int64  `json:",omitempty"`
 	InitiatorId int64  `sql:"unique:connected_users" json:",omitempty"`
 	RecipientId int64  `sql:"unique:connected_users" json:",omitempty"`
+	Status      string `sql:",notnull,default:'accepted'" json:",omitempty"`
 	Recipient   *User  `json:",omitempty"`
 	Initiator   *User  `json:",omitempty"`
 	Users       []User `pg:",many2many:user_connections" json:",omitempty"`
 	BaseModel
 }
 
+// Connection statuses
+const (
+	PendingConnection  = "pending"
+	AcceptedConnection = "accepted"
+)
+
 type Message struct {
-	Id         int64
-	Content    string
-	Connection *Connection
+	Id           int64
+	ConnectionId int64       `sql:",on_delete:SET NULL" json:",omitempty"`
+	SenderId     int64       `sql:",notnull,on_delete:CASCADE"`
+	RecipientId  int64       `sql:",notnull,on_delete:CASCADE"`
+	Content      string      `sql:",notnull"`
+	ReadAt       *time.Time  `json:",omitempty"`
+	Connection   *Connection `json:",omitempty"`
+	Sender       *User       `json:",omitempty"`
+	Recipient    *User       `json:",omitempty"`
 	BaseModel
 }
 
@@ -134,30 +258,97 @@ func (m Message) String() string {
 
 type Comment struct {
 	Id         int64
-	UserId     int64     `sql:",notnull"`
-	ResourceId int64     `sql:",notnull"`
-	Text       string    `sql:",notnull"`
-	Likes      int64     `sql:",notnull"`
-	Resource   *Resource `json:",omitempty"`
+	UserId     int64 `sql:",notnull"`
+	ResourceId int64 `sql:",notnull"`
+	// replies belong to the comment they answer, at one more depth
+	ParentId int64  `json:",omitempty" sql:",on_delete:CASCADE"`
+	Depth    int    `sql:",notnull,default:0" json:",omitempty"`
+	Text     string `sql:",notnull"`
+	Likes    int64  `sql:",notnull"`
+	// counts of the reactions other than likes
+	Reactions map[string]int64 `sql:",notnull,default:'{}'" json:",omitempty"`
+	// the reactions of the user viewing the comment
+	MyReactions []string `sql:"-" json:",omitempty"`
+	// edited comments keep their previous texts as comment edits
+	EditedAt *time.Time `json:",omitempty"`
+	// deleted comments lose their text but stay as placeholders in threads
+	DeletedAt *time.Time `json:",omitempty"`
+	// hidden comments await review by a moderator
+	HiddenAt *time.Time `json:",omitempty"`
+	Resource *Resource  `json:",omitempty"`
+	Parent   *Comment   `json:",omitempty"`
+	Replies  []*Comment `sql:"-" json:",omitempty"`
+	Mentions []*Mention `sql:"-" json:",omitempty"`
 	BaseModel
 }
 
+// LikeReaction the reaction counted in the likes of a comment
+const LikeReaction = "like"
+
+// CommentReactions the reactions users can give comments
+var CommentReactions = []string{LikeReaction, "love", "laugh", "insightful", "confused", "celebrate"}
+
+// CommentReaction a reaction of a user to a comment; a user can give each
+// reaction to a comment once
+type CommentReaction struct {
+	CommentId int64      `sql:",pk,on_delete:CASCADE"`
+	UserId    int64      `sql:",pk,on_delete:CASCADE"`
+	Reaction  string     `sql:",pk"`
+	CreatedAt *time.Time `sql:",notnull,default:now()"`
+	Comment   *Comment   `json:",omitempty"`
+	User      *User      `json:",omitempty"`
+}
+
+// Mention a user mentioned as @username in a comment, or in the description
+// of a resource when CommentId is 0; Offset and Length count characters
+type Mention struct {
+	Id         int64      `json:"-"`
+	UserId     int64      `sql:",notnull,on_delete:CASCADE"`
+	ResourceId int64      `sql:",notnull,on_delete:CASCADE" json:"-"`
+	CommentId  int64      `sql:",on_delete:CASCADE" json:"-"`
+	Offset     int        `sql:",notnull"`
+	Length     int        `sql:",notnull"`
+	CreatedAt  *time.Time `sql:",notnull,default:now()" json:"-"`
+	User       *User      `json:",omitempty"`
+	Resource   *Resource  `json:",omitempty"`
+	Comment    *Comment   `json:",omitempty"`
+}
+
+// CommentEdit a previous text of an edited comment
+type CommentEdit struct {
+	Id        int64
+	CommentId int64      `sql:",notnull,on_delete:CASCADE"`
+	Text      string     `sql:",notnull"`
+	CreatedAt *time.Time `sql:",notnull,default:now()"`
+	Comment   *Comment   `json:",omitempty"`
+}
+
 func (c Comment) String() string {
 	return fmt.Sprintf("Comment<%d %s %d>", c.Id, c.Text, c.UserId)
 }
 
 type Resource struct {
+	tableName struct{} `pg:",discard_unknown_columns"`
+
 	Id              int64
-	UserId          int64      `sql:",notnull" json:",omitempty"`
-	Title           string     `sql:",notnull" json:",omitempty"`
-	Link            string     `sql:",unique,notnull" json:",omitempty"`
-	Privacy         string     `sql:",notnull" json:",omitempty"`
-	Type            string     `sql:",notnull" json:",omitempty"`
-	Views           int64      `json:",omitempty"`
-	Recommendations int64      `json:",omitempty"`
-	User            *User      `json:",omitempty"`
-	Comments        []*Comment `json:",omitempty"`
-	Tags            []*Tag     `pg:",many2many:resource_tags" json:",omitempty"`
+	UserId          int64  `sql:",notnull" json:",omitempty"`
+	Title           string `sql:",notnull" json:",omitempty"`
+	Link            string `sql:",unique,notnull" json:",omitempty"`
+	Privacy         string `sql:",notnull" json:",omitempty"`
+	Type            string `sql:",notnull" json:",omitempty"`
+	Views           int64  `json:",omitempty"`
+	Recommendations int64  `json:",omitempty"`
+	Description     string `json:",omitempty"`
+	// the number and average of the star ratings of the resource
+	RatingCount   int64   `sql:",notnull,default:0" json:",omitempty"`
+	RatingAverage float64 `sql:",notnull,default:0" json:",omitempty"`
+	// hidden resources await review by a moderator
+	HiddenAt *time.Time `json:",omitempty"`
+	User     *User      `json:",omitempty"`
+	Comments []*Comment `json:",omitempty"`
+	Tags     []*Tag     `pg:",many2many:resource_tags" json:",omitempty"`
+	// the mentions in the description
+	Mentions []*Mention `sql:"-" json:",omitempty"`
 	BaseModel
 }
 
@@ -166,19 +357,60 @@ func (r Resource) String() string {
 }
 
 type Collection struct {
-	Id        int64
-	Name      string `sql:",unique,notnull"`
-	UserId    int64
+	tableName struct{} `pg:",discard_unknown_columns"`
+
+	Id      int64
+	Name    string `sql:",unique,notnull"`
+	UserId  int64
+	Privacy string `sql:",notnull,default:'private'" json:",omitempty"`
+	// learning paths are followed step by step, in the order of their
+	// resources
+	LearningPath bool `sql:",notnull,default:false" json:",omitempty"`
+	// the token of the link to an unlisted collection, only shown to the
+	// owner
+	ShareToken string `sql:",unique" json:"-"`
+	// the public collection the collection is a copy of
+	ForkedFromId int64       `sql:",on_delete:SET NULL" json:",omitempty"`
+	ForkedFrom   *Collection `json:",omitempty"`
+	// hidden collections await review by a moderator
+	HiddenAt  *time.Time `json:",omitempty"`
 	Resources []*Resource
 	Tags      []Tag `pg:",many2many:collection_tags"`
 	BaseModel
 }
 
+// Collection privacies; unlisted collections can only be viewed through
+// their share token
+const (
+	PrivateCollection  = "private"
+	UnlistedCollection = "unlisted"
+	PublicCollection   = "public"
+)
+
+// CollectionPrivacies all privacies of a collection
+var CollectionPrivacies = []string{
+	PrivateCollection,
+	UnlistedCollection,
+	PublicCollection,
+}
+
+// CollectionFollower a user notified when resources are added to a
+// collection
+type CollectionFollower struct {
+	CollectionId int64       `sql:",pk,on_delete:CASCADE"`
+	UserId       int64       `sql:",pk,on_delete:CASCADE"`
+	Collection   *Collection `json:",omitempty"`
+	User         *User       `json:",omitempty"`
+	BaseModel
+}
+
 func (c Collection) String() string {
 	return fmt.Sprintf("Collection<%d %s>", c.Id, c.Name)
 }
 
 type Tag struct {
+	tableName struct{} `pg:",discard_unknown_columns"`
+
 	Id    int64
 	Title string `sql:",unique,notnull"`
 }
@@ -192,9 +424,40 @@ type ResourceTag struct {
 	ResourceId int64 `sql:",pk"`
 }
 
+// CollectionCollaborator a user invited to view or edit the collection of
+// another user
+type CollectionCollaborator struct {
+	CollectionId int64       `sql:",pk,on_delete:CASCADE"`
+	UserId       int64       `sql:",pk,on_delete:CASCADE"`
+	InviterId    int64       `sql:",notnull,on_delete:CASCADE" json:",omitempty"`
+	Role         string      `sql:",notnull"`
+	Status       string      `sql:",notnull,default:'pending'"`
+	Collection   *Collection `json:",omitempty"`
+	User         *User       `json:",omitempty"`
+	Inviter      *User       `json:",omitempty"`
+	BaseModel
+}
+
+// Collaborator roles; viewers can see a collection, editors can also
+// add, remove and reorder its resources and rename it
+const (
+	ViewerCollaborator = "viewer"
+	EditorCollaborator = "editor"
+)
+
+var CollaboratorRoles = []string{ViewerCollaborator, EditorCollaborator}
+
+// Invitation statuses
+const (
+	PendingInvitation  = "pending"
+	AcceptedInvitation = "accepted"
+)
+
 type CollectionTag struct {
-	TagId        int64 `sql:",pk"`
-	CollectionId int64 `sql:",pk"`
+	TagId        int64       `sql:",pk,on_delete:CASCADE"`
+	CollectionId int64       `sql:",pk,on_delete:CASCADE"`
+	Tag          *Tag        `json:",omitempty"`
+	Collection   *Collection `json:",omitempty"`
 }
 
 type UserConnection struct {
@@ -203,11 +466,207 @@ type UserConnection struct {
 }
 
 type Recommendation struct {
-	ResourceId int64 `sql:",pk"`
-	UserId     int64 `sql:",pk"`
+	ResourceId int64     `sql:",pk,on_delete:CASCADE"`
+	UserId     int64     `sql:",pk,on_delete:CASCADE"`
+	CreatedAt  time.Time `sql:",notnull,default:now()"`
+	Resource   *Resource `json:",omitempty"`
+	User       *User     `json:",omitempty"`
+}
+
+// Rating the 1 to 5 star rating and optional review of a resource by a user
+type Rating struct {
+	ResourceId int64     `sql:",pk,on_delete:CASCADE"`
+	UserId     int64     `sql:",pk,on_delete:CASCADE"`
+	Stars      int       `sql:",notnull"`
+	Review     string    `json:",omitempty"`
+	Resource   *Resource `json:",omitempty"`
+	User       *User     `json:",omitempty"`
+	BaseModel
 }
 
 type ResourceCollection struct {
-	ResourceId   int64 `sql:",pk"`
-	CollectionId int64 `sql:",pk"`
+	ResourceId   int64 `sql:",pk,on_delete:CASCADE"`
+	CollectionId int64 `sql:",pk,on_delete:CASCADE"`
+	// the place of the resource in the collection, starting at 1
+	Position int `sql:",notnull,default:0"`
+	// the title of the section of a learning path the resource is a step of
+	Section    string      `json:",omitempty"`
+	Resource   *Resource   `json:",omitempty"`
+	Collection *Collection `json:",omitempty"`
+}
+
+// Enrollment a user following a learning path
+type Enrollment struct {
+	CollectionId int64       `sql:",pk,on_delete:CASCADE"`
+	UserId       int64       `sql:",pk,on_delete:CASCADE"`
+	Collection   *Collection `json:",omitempty"`
+	User         *User       `json:",omitempty"`
+	BaseModel
+}
+
+// StepCompletion a step of a learning path completed by a user
+type StepCompletion struct {
+	CollectionId int64       `sql:",pk,on_delete:CASCADE"`
+	ResourceId   int64       `sql:",pk,on_delete:CASCADE"`
+	UserId       int64       `sql:",pk,on_delete:CASCADE"`
+	CreatedAt    time.Time   `sql:",notnull,default:now()"`
+	Collection   *Collection `json:",omitempty"`
+	Resource     *Resource   `json:",omitempty"`
+	User         *User       `json:",omitempty"`
+}
+
+// Notification types
+const (
+	FollowerNotification       = "follower"
+	FollowRequestNotification  = "followRequest"
+	RecommendationNotification = "recommendation"
+	CommentNotification        = "comment"
+	CollectionNotification     = "collection"
+	ReportNotification         = "report"
+	MentionNotification        = "mention"
+	InvitationNotification     = "invitation"
+	// FollowedCollectionNotification a resource was added to a followed
+	// collection
+	FollowedCollectionNotification = "followedCollection"
+	// WarningNotification a warning from a moderator, which cannot be
+	// turned off
+	WarningNotification = "warning"
+)
+
+// NotificationTypes all notification types a user can turn on or off
+var NotificationTypes = []string{
+	FollowerNotification,
+	FollowRequestNotification,
+	RecommendationNotification,
+	CommentNotification,
+	CollectionNotification,
+	ReportNotification,
+	MentionNotification,
+	InvitationNotification,
+	FollowedCollectionNotification,
+}
+
+type Notification struct {
+	Id           int64
+	UserId       int64       `sql:",notnull,on_delete:CASCADE"`
+	ActorId      int64       `sql:",notnull,on_delete:CASCADE"`
+	Type         string      `sql:",notnull"`
+	ResourceId   int64       `sql:",on_delete:CASCADE" json:",omitempty"`
+	CommentId    int64       `sql:",on_delete:CASCADE" json:",omitempty"`
+	CollectionId int64       `sql:",on_delete:CASCADE" json:",omitempty"`
+	ReportId     int64       `json:",omitempty"`
+	ReadAt       *time.Time  `json:",omitempty"`
+	User         *User       `json:",omitempty"`
+	Actor        *User       `json:",omitempty"`
+	Resource     *Resource   `json:",omitempty"`
+	Comment      *Comment    `json:",omitempty"`
+	Collection   *Collection `json:",omitempty"`
+	BaseModel
+}
+
+func (n Notification) String() string {
+	return fmt.Sprintf("Notification<%d %s %d>", n.Id, n.Type, n.UserId)
+}
+
+type NotificationSetting struct {
+	UserId  int64  `sql:",pk,on_delete:CASCADE"`
+	Type    string `sql:",pk"`
+	Enabled bool   `sql:",notnull"`
+	User    *User  `json:",omitempty"`
+}
+
+type Block struct {
+	BlockerId int64     `sql:",pk,on_delete:CASCADE"`
+	BlockedId int64     `sql:",pk,on_delete:CASCADE"`
+	CreatedAt time.Time `sql:",notnull,default:now()"`
+	Blocker   *User     `json:",omitempty"`
+	Blocked   *User     `json:",omitempty"`
+}
+
+type Mute struct {
+	MuterId   int64     `sql:",pk,on_delete:CASCADE"`
+	MutedId   int64     `sql:",pk,on_delete:CASCADE"`
+	CreatedAt time.Time `sql:",notnull,default:now()"`
+	Muter     *User     `json:",omitempty"`
+	Muted     *User     `json:",omitempty"`
+}
+
+type RefreshToken struct {
+	Id        int64
+	UserId    int64      `sql:",notnull,on_delete:CASCADE"`
+	TokenHash string     `sql:",unique,notnull" json:"-"`
+	UserAgent string     `json:",omitempty"`
+	ExpiresAt time.Time  `sql:",notnull"`
+	RevokedAt *time.Time `json:",omitempty"`
+	User      *User      `json:",omitempty"`
+	BaseModel
+}
+
+type PasswordResetToken struct {
+	Id        int64
+	UserId    int64      `sql:",notnull,on_delete:CASCADE"`
+	TokenHash string     `sql:",unique,notnull" json:"-"`
+	ExpiresAt time.Time  `sql:",notnull"`
+	UsedAt    *time.Time `json:",omitempty"`
+	User      *User      `json:",omitempty"`
+	BaseModel
+}
+
+// AdminAction a record of an action by a moderator or admin; it has no
+// foreign keys so it outlives the users and content it refers to
+type AdminAction struct {
+	Id         int64
+	AdminId    int64                  `sql:",notnull"`
+	Action     string                 `sql:",notnull"`
+	TargetType string                 `sql:",notnull"`
+	TargetId   int64                  `sql:",notnull"`
+	Details    map[string]interface{} `json:",omitempty"`
+	CreatedAt  *time.Time             `sql:",notnull,default:now()"`
+}
+
+// AuditEvent an append-only record of a security-relevant event; it has no
+// foreign keys so it outlives the users and content it refers to
+type AuditEvent struct {
+	Id         int64
+	ActorId    int64                  `json:",omitempty"`
+	Action     string                 `sql:",notnull"`
+	TargetType string                 `json:",omitempty"`
+	TargetId   int64                  `json:",omitempty"`
+	Ip         string                 `json:",omitempty"`
+	UserAgent  string                 `json:",omitempty"`
+	Before     map[string]interface{} `json:",omitempty"`
+	After      map[string]interface{} `json:",omitempty"`
+	Details    map[string]interface{} `json:",omitempty"`
+	CreatedAt  *time.Time             `sql:",notnull,default:now()"`
+}
+
+const (
+	OpenReport     = "open"
+	ClaimedReport  = "claimed"
+	ResolvedReport = "resolved"
+)
+
+// ReportTargets the kinds of content that can be reported
+var ReportTargets = []string{"resource", "comment", "collection", "user"}
+
+// ReportReasons the reasons content can be reported for
+var ReportReasons = []string{"spam", "abuse", "harassment", "inappropriate", "other"}
+
+// ReportResolutions the ways a moderator can resolve a report
+var ReportResolutions = []string{"dismiss", "hide", "warn", "suspend"}
+
+type Report struct {
+	Id          int64
+	ReporterId  int64      `sql:",notnull,on_delete:CASCADE"`
+	TargetType  string     `sql:",notnull"`
+	TargetId    int64      `sql:",notnull"`
+	Reason      string     `sql:",notnull"`
+	Details     string     `json:",omitempty"`
+	Status      string     `sql:",notnull,default:'open'"`
+	ModeratorId int64      `json:",omitempty"`
+	Resolution  string     `json:",omitempty"`
+	Note        string     `json:",omitempty"`
+	ResolvedAt  *time.Time `json:",omitempty"`
+	Reporter    *User      `json:",omitempty"`
+	BaseModel
 }
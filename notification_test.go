@@ -0,0 +1,115 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+)
+
+func TestNotifications(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedNotification struct {
+		UserId  int64
+		ActorId int64
+		Type    string
+	}
+	type ExpectedResponse struct {
+		Notifications []ExpectedNotification
+		TotalCount    int
+		UnreadCount   int
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	followUser := fmt.Sprintf(`{"userId": %v}`, user.Id)
+
+	t.Run("user is notified of a new follower", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/connection").
+			Set("authorization", anotherUserToken).
+			Send(followUser).
+			Expect(200).
+			End()
+
+		expectedResponse := ExpectedResponse{
+			[]ExpectedNotification{{user.Id, anotherUser.Id, "follower"}},
+			1,
+			1,
+		}
+		Request(testServer.URL, t).
+			Get("/api/v1/notification").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+	})
+
+	t.Run("cannot update settings of unknown notification types", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/notification/settings").
+			Set("authorization", userToken).
+			Send(`{"likes": false}`).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"\"likes\" is not a notification type"}`).
+			End()
+	})
+
+	t.Run("user is not notified of turned off notification types", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/notification/settings").
+			Set("authorization", userToken).
+			Send(`{"follower": false}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Notification settings updated","settings":{
+				"collection":true,"comment":true,"followRequest":true,
+				"followedCollection":true,"follower":false,"invitation":true,
+				"mention":true,"recommendation":true,"report":true}}`).
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/connection").
+			Set("authorization", thirdUserToken).
+			Send(followUser).
+			Expect(200).
+			End()
+
+		Request(testServer.URL, t).
+			Get("/api/v1/notification?unread=true").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(ExpectedResponse{
+				[]ExpectedNotification{{user.Id, anotherUser.Id, "follower"}},
+				1,
+				1,
+			}).
+			End()
+	})
+
+	t.Run("user can mark all notifications as read", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put("/api/v1/notification").
+			Set("authorization", userToken).
+			Send(`{"read": true}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Notifications updated","updatedCount":1}`).
+			End()
+	})
+}
@@ -0,0 +1,256 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+// addTestRaters add users to rate resources
+func addTestRaters(t *testing.T, count int) []string {
+	var tokens []string
+	for i := 0; i < count; i++ {
+		_, token := addTestUser(t, map[string]interface{}{
+			"username":    fmt.Sprintf("rater%v", i),
+			"email":       fmt.Sprintf("rater%v@gmail.com", i),
+			"phoneNumber": fmt.Sprintf("0819000000%v", i),
+			"password":    "rater",
+		})
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// rateTestResource rate a resource through the API; it is safe to call
+// from other goroutines
+func rateTestResource(
+	t *testing.T, testServer *httptest.Server, token string, resourceId int64, stars int,
+) {
+	request, _ := http.NewRequest("PUT", fmt.Sprintf(
+		"%v/api/v1/resource/%v/rating", testServer.URL, resourceId,
+	), strings.NewReader(fmt.Sprintf(`{"stars": %v}`, stars)))
+	request.Header.Set("authorization", token)
+	response, err := testServer.Client().Do(request)
+	if err != nil {
+		t.Error(err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200; Got status %v", response.StatusCode)
+	}
+}
+
+func TestRatings(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	_, raterToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = owner.Id
+	resource := addTestResource(t, testResource)
+
+	privateResource := dummyData["privateResource"].(map[string]interface{})
+	privateResource["userId"] = owner.Id
+	hidden := addTestResource(t, privateResource)
+
+	ratingURI := fmt.Sprintf("/api/v1/resource/%v/rating", resource.Id)
+
+	t.Run("cannot rate with invalid stars", func(t *testing.T) {
+		for _, body := range []string{`{"stars": 0}`, `{"stars": 6}`, `{"stars": 4.5}`} {
+			Request(testServer.URL, t).
+				Put(ratingURI).
+				Set("authorization", raterToken).
+				Send(body).
+				Expect(400).
+				Expect("Content-Type", "application/json").
+				Expect(`{"error":"stars must be a whole number from 1 to 5"}`).
+				End()
+		}
+	})
+
+	t.Run("cannot rate their own resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(ratingURI).
+			Set("authorization", ownerToken).
+			Send(`{"stars": 5}`).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot rate your own resource"}`).
+			End()
+	})
+
+	t.Run("cannot rate a resource they cannot access", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/resource/%v/rating", hidden.Id)).
+			Set("authorization", raterToken).
+			Send(`{"stars": 5}`).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Either this resource does not exist or you cannot access it"}`).
+			End()
+	})
+
+	t.Run("can rate and review a resource", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(ratingURI).
+			Set("authorization", raterToken).
+			Send(`{"stars": 4, "review": "  Clear and to the point  "}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Resource rated","ratingAverage":4,"ratingCount":1}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(ratingURI).
+			Set("authorization", thirdUserToken).
+			Send(`{"stars": 1}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Resource rated","ratingAverage":2.5,"ratingCount":2}`).
+			End()
+	})
+
+	t.Run("can change their rating", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Put(ratingURI).
+			Set("authorization", thirdUserToken).
+			Send(`{"stars": 2}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Resource rated","ratingAverage":3,"ratingCount":2}`).
+			End()
+	})
+
+	t.Run("can list the reviews of a resource", func(t *testing.T) {
+		request, _ := http.NewRequest("GET", fmt.Sprintf(
+			"%v/api/v1/resource/%v/ratings?reviewed=true", testServer.URL, resource.Id,
+		), nil)
+		request.Header.Set("authorization", ownerToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct {
+			TotalCount int
+			Ratings    []Rating
+		}
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		if payload.TotalCount != 1 || payload.Ratings[0].Review != "Clear and to the point" ||
+			payload.Ratings[0].User.Username != "anotherUser" {
+			t.Fatalf("Expected the trimmed review of anotherUser; Got %+v", payload)
+		}
+	})
+
+	t.Run("counts concurrent ratings", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i, token := range addTestRaters(t, 5) {
+			wg.Add(1)
+			go func(token string, stars int) {
+				defer wg.Done()
+				rateTestResource(t, testServer, token, resource.Id, stars)
+			}(token, i+1)
+		}
+		wg.Wait()
+
+		var updated Resource
+		err := app.Db.Model(&updated).
+			Column("rating_count", "rating_average").
+			Where("id = ?", resource.Id).
+			Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if updated.RatingCount != 7 || updated.RatingAverage != 3 {
+			t.Fatalf("Expected 7 ratings averaging 3; Got %+v", updated)
+		}
+	})
+
+	t.Run("can remove their rating", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(ratingURI).
+			Set("authorization", raterToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Rating removed","ratingAverage":2.8333333333333335,"ratingCount":6}`).
+			End()
+
+		Request(testServer.URL, t).
+			Delete(ratingURI).
+			Set("authorization", raterToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You have not rated this resource"}`).
+			End()
+	})
+}
+
+func TestTopRatedResources(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	owner, ownerToken := addTestUser(t, testUser)
+	raters := addTestRaters(t, 4)
+
+	var resources []Resource
+	for i := 0; i < 3; i++ {
+		resources = append(resources, addTestResource(t, map[string]interface{}{
+			"userId":  owner.Id,
+			"title":   fmt.Sprintf("Rated resource %v", i),
+			"type":    "textual",
+			"link":    fmt.Sprintf("https://localhost.textual/rated/%v.pdf", i),
+			"privacy": "public",
+		}))
+	}
+	// many good ratings, a single perfect rating and a couple of bad ones
+	for i, stars := range []int{5, 5, 5, 4} {
+		rateTestResource(t, testServer, raters[i], resources[0].Id, stars)
+	}
+	rateTestResource(t, testServer, raters[0], resources[1].Id, 5)
+	rateTestResource(t, testServer, raters[0], resources[2].Id, 1)
+	rateTestResource(t, testServer, raters[1], resources[2].Id, 1)
+
+	t.Run("ranks resources by their Bayesian rating", func(t *testing.T) {
+		request, _ := http.NewRequest(
+			"GET", testServer.URL+"/api/v1/resource?sort=rating", nil,
+		)
+		request.Header.Set("authorization", ownerToken)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct{ Resources []Resource }
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		var obtained []int64
+		for _, resource := range payload.Resources {
+			obtained = append(obtained, resource.Id)
+		}
+		expected := fmt.Sprint(
+			[]int64{resources[0].Id, resources[1].Id, resources[2].Id},
+		)
+		if fmt.Sprint(obtained) != expected {
+			t.Fatalf("Expected resources in order %v; Got %v", expected, obtained)
+		}
+	})
+}
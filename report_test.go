@@ -0,0 +1,215 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+	. "WeKnow_api/model"
+)
+
+func TestReports(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	customizeEnvVariables(t, map[string]string{"REPORT_HIDE_THRESHOLD": "2"})
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	moderator, moderatorToken := addTestUser(t, anotherTestUser)
+	setTestUserRole(t, moderator.Id, ModeratorRole)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	reportResource := fmt.Sprintf(
+		`{"targetType": "resource", "targetId": %v, "reason": "spam"}`,
+		resource.Id,
+	)
+
+	t.Run("cannot report with an unknown reason", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", thirdUserToken).
+			Send(fmt.Sprintf(
+				`{"targetType": "resource", "targetId": %v, "reason": "boring"}`,
+				resource.Id,
+			)).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"reason must be one of spam, abuse, harassment, inappropriate, other"}`).
+			End()
+	})
+
+	t.Run("cannot report missing content", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", thirdUserToken).
+			Send(`{"targetType": "comment", "targetId": 404, "reason": "spam"}`).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"The content does not exist"}`).
+			End()
+	})
+
+	t.Run("cannot report own content", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", userToken).
+			Send(reportResource).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You cannot report your own content"}`).
+			End()
+	})
+
+	t.Run("can report content once", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", thirdUserToken).
+			Send(reportResource).
+			Expect(201).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", thirdUserToken).
+			Send(reportResource).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You have already reported this"}`).
+			End()
+	})
+
+	t.Run("can report content once with simultaneous requests", func(t *testing.T) {
+		comment := addTestComment(t, map[string]interface{}{
+			"resourceId": resource.Id,
+			"userId":     user.Id,
+			"text":       "Buy now",
+		})
+		reportComment := fmt.Sprintf(
+			`{"targetType": "comment", "targetId": %v, "reason": "spam"}`,
+			comment.Id,
+		)
+		statuses := make([]int, 2)
+		var wg sync.WaitGroup
+		for i := range statuses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				request, _ := http.NewRequest(
+					"POST", testServer.URL+"/api/v1/report",
+					strings.NewReader(reportComment),
+				)
+				request.Header.Set("authorization", thirdUserToken)
+				if response, err := testServer.Client().Do(request); err == nil {
+					statuses[i] = response.StatusCode
+					response.Body.Close()
+				}
+			}(i)
+		}
+		wg.Wait()
+		if statuses[0]+statuses[1] != http.StatusCreated+http.StatusConflict {
+			t.Fatalf("Expected one report to be created and one refused; Got %v", statuses)
+		}
+	})
+
+	t.Run("hides content with enough reports", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post("/api/v1/report").
+			Set("authorization", moderatorToken).
+			Send(reportResource).
+			Expect(201).
+			Expect("Content-Type", "application/json").
+			End()
+
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/resource/%v", resource.Id)).
+			Set("authorization", thirdUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Either this resource does not exist or you cannot access it"}`).
+			End()
+	})
+
+	t.Run("users cannot see the moderation queue", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/admin/reports").
+			Set("authorization", thirdUserToken).
+			Expect(403).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You are not allowed to perform this action"}`).
+			End()
+	})
+
+	t.Run("moderators can claim and dismiss reports", func(t *testing.T) {
+		var report Report
+		err := app.Db.Model(&report).
+			Where("target_type = 'resource' AND target_id = ?", resource.Id).
+			Limit(1).
+			Select()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/reports/%v/claim", report.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"claimed": true}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Report claimed"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/reports/%v/resolve", report.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"resolution": "dismiss", "note": "Not spam"}`).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Report resolved","resolvedCount":2}`).
+			End()
+
+		Request(testServer.URL, t).
+			Put(fmt.Sprintf("/api/v1/admin/reports/%v/resolve", report.Id)).
+			Set("authorization", moderatorToken).
+			Send(`{"resolution": "hide"}`).
+			Expect(409).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Report is already resolved"}`).
+			End()
+
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/resource/%v", resource.Id)).
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+
+	t.Run("reporters are told the outcome", func(t *testing.T) {
+		count, err := app.Db.Model(&Notification{}).
+			Where("type = ? AND report_id IS NOT NULL", ReportNotification).
+			Count()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		// the moderator who reported is not notified of their own decision
+		if count != 1 {
+			t.Fatalf("Expected 1 report notification; Got %v", count)
+		}
+	})
+}
@@ -6,8 +6,10 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	. "WeKnow_api/libs/supertest"
 	. "WeKnow_api/model"
@@ -30,7 +32,7 @@ func TestRecommendResource(t *testing.T) {
 
 	t.Run("cannot recommend resource with id 0", func(t *testing.T) {
 		Request(testServer.URL, t).
-			Get(resourceURI+"0").
+			Post(resourceURI+"0").
 			Set("authorization", userToken).
 			Expect(400).
 			Expect("Content-Type", "application/json").
@@ -40,7 +42,7 @@ func TestRecommendResource(t *testing.T) {
 
 	t.Run("cannot recommend nonexistent resource", func(t *testing.T) {
 		Request(testServer.URL, t).
-			Get(resourceURI+"238").
+			Post(resourceURI+"238").
 			Set("authorization", userToken).
 			Expect(404).
 			Expect("Content-Type", "application/json").
@@ -48,9 +50,9 @@ func TestRecommendResource(t *testing.T) {
 			End()
 	})
 
-	t.Run("can recommend a resource", func(t *testing.T) {
+	t.Run("can recommend a resource without a request body", func(t *testing.T) {
 		Request(testServer.URL, t).
-			Get(fmt.Sprintf("%v%v", resourceURI, resource.Id)).
+			Post(fmt.Sprintf("%v%v", resourceURI, resource.Id)).
 			Set("authorization", userToken).
 			Expect(200).
 			Expect("Content-Type", "application/json").
@@ -73,7 +75,7 @@ func TestRecommendResource(t *testing.T) {
 
 				client := testServer.Client()
 				uri := fmt.Sprintf("%v%v%v", testServer.URL, resourceURI, resource.Id)
-				request, _ := http.NewRequest("GET", uri, nil)
+				request, _ := http.NewRequest("POST", uri, nil)
 				request.Header.Set("authorization", thirdUserToken)
 
 				response, err := client.Do(request)
@@ -112,7 +114,7 @@ func TestRecommendResource(t *testing.T) {
 
 				client := testServer.Client()
 				uri := fmt.Sprintf("%v%v%v", testServer.URL, resourceURI, resource.Id)
-				request, _ := http.NewRequest("GET", uri, nil)
+				request, _ := http.NewRequest("POST", uri, nil)
 				request.Header.Set("authorization", anotherUserToken)
 
 				response, err := client.Do(request)
@@ -154,7 +156,7 @@ func TestRecommendResource(t *testing.T) {
 
 	t.Run("cannot recommend a resource twice", func(t *testing.T) {
 		Request(testServer.URL, t).
-			Get(fmt.Sprintf("%v%v", resourceURI, resource.Id)).
+			Post(fmt.Sprintf("%v%v", resourceURI, resource.Id)).
 			Set("authorization", userToken).
 			Expect(409).
 			Expect("Content-Type", "application/json").
@@ -163,6 +165,128 @@ func TestRecommendResource(t *testing.T) {
 	})
 }
 
+func TestWithdrawRecommendation(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	resource := addTestResource(t, testResource)
+
+	testResource = dummyData["privateResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	privateResource := addTestResource(t, testResource)
+
+	for _, recommended := range []Resource{resource, privateResource} {
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/resource/recommend/%v", recommended.Id)).
+			Set("authorization", userToken).
+			Expect(200).
+			End()
+	}
+	Request(testServer.URL, t).
+		Post(fmt.Sprintf("/api/v1/resource/recommend/%v", resource.Id)).
+		Set("authorization", anotherUserToken).
+		Expect(200).
+		End()
+
+	// getRecommendations decode the recommendations listed by a request
+	getRecommendations := func(t *testing.T, token, uri string) (int, []Recommendation) {
+		request, _ := http.NewRequest("GET", testServer.URL+uri, nil)
+		request.Header.Set("authorization", token)
+		response, err := testServer.Client().Do(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var payload struct {
+			TotalCount      int
+			Recommenders    []Recommendation
+			Recommendations []Recommendation
+		}
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err.Error())
+		}
+		return payload.TotalCount, append(payload.Recommenders, payload.Recommendations...)
+	}
+
+	t.Run("cannot recommend a resource they cannot access", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/resource/recommend/%v", privateResource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Resource does not exist"}`).
+			End()
+	})
+
+	t.Run("can list the recommenders of a resource", func(t *testing.T) {
+		count, recommenders := getRecommendations(t, userToken,
+			fmt.Sprintf("/api/v1/resource/%v/recommenders", resource.Id),
+		)
+		if count != 2 || recommenders[0].User.Id != anotherUser.Id {
+			t.Fatalf("Expected the latest recommender first; Got %+v", recommenders)
+		}
+
+		Request(testServer.URL, t).
+			Get(fmt.Sprintf("/api/v1/resource/%v/recommenders", privateResource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"Either this resource does not exist or you cannot access it"}`).
+			End()
+	})
+
+	t.Run("can list the resources a user recommended", func(t *testing.T) {
+		uri := fmt.Sprintf("/api/v1/user/%v/recommendations", user.Id)
+		if count, _ := getRecommendations(t, userToken, uri); count != 2 {
+			t.Fatalf("Expected 2 recommended resources; Got %v", count)
+		}
+
+		count, recommendations := getRecommendations(t, anotherUserToken, uri)
+		if count != 1 || recommendations[0].Resource.Id != resource.Id {
+			t.Fatalf("Expected only the accessible resource; Got %+v", recommendations)
+		}
+	})
+
+	t.Run("can withdraw a recommendation", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/resource/recommend/%v", resource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Recommendation withdrawn","recommendationCount":1}`).
+			End()
+	})
+
+	t.Run("cannot withdraw a recommendation twice", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Delete(fmt.Sprintf("/api/v1/resource/recommend/%v", resource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(404).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"You have not recommended this resource"}`).
+			End()
+	})
+
+	t.Run("can recommend a resource again", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Post(fmt.Sprintf("/api/v1/resource/recommend/%v", resource.Id)).
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(`{"message":"Recommend resource successful",
+			"recommendationCount":2}`).
+			End()
+	})
+}
+
 func TestGetResource(t *testing.T) {
 	initializeDatabase(t)
 	testServer := httptest.NewServer(app.Router)
@@ -986,3 +1110,151 @@ func TestDeleteResource(t *testing.T) {
 			End()
 	})
 }
+
+func TestGetResources(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedResource struct {
+		Id      int64
+		Title   string
+		Privacy string
+		Type    string
+	}
+	type ExpectedResponse struct {
+		Resources  []ExpectedResource
+		TotalCount int
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	anotherUser, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	thirdTestUser := dummyData["thirdTestUser"].(map[string]interface{})
+	_, thirdUserToken := addTestUser(t, thirdTestUser)
+
+	testConnectionData := map[string]interface{}{
+		"initiatorId": anotherUser.Id,
+		"recipientId": user.Id,
+	}
+	addTestConnection(t, testConnectionData)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	publicResource := addTestResource(t, testResource)
+
+	testResource = dummyData["privateResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	privateResource := addTestResource(t, testResource)
+
+	testResource = dummyData["followersResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	followersResource := addTestResource(t, testResource)
+
+	toExpected := func(resources ...Resource) ExpectedResponse {
+		expected := ExpectedResponse{TotalCount: len(resources)}
+		for _, resource := range resources {
+			expected.Resources = append(expected.Resources, ExpectedResource{
+				resource.Id,
+				resource.Title,
+				resource.Privacy,
+				resource.Type,
+			})
+		}
+		return expected
+	}
+
+	t.Run("owner can list all own resources", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(followersResource, privateResource, publicResource)).
+			End()
+	})
+
+	t.Run("follower can list public and followers resources", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(followersResource, publicResource)).
+			End()
+	})
+
+	t.Run("others can only list public resources", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource").
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(publicResource)).
+			End()
+	})
+
+	t.Run("can filter resources by type and tag", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?type=textual&tag=golang").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(privateResource)).
+			End()
+	})
+
+	t.Run("can sort resources in ascending order", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?sort=createdAt&order=asc").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(publicResource, followersResource)).
+			End()
+	})
+
+	t.Run("cannot sort resources by an unknown field", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?sort=title").
+			Set("authorization", userToken).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"sort must be one of 'views', 'recommendations', 'rating' or 'createdAt'"}`).
+			End()
+	})
+
+	t.Run("can filter resources by creation time", func(t *testing.T) {
+		hourAgo := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?to="+url.QueryEscape(hourAgo)).
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(struct{ TotalCount int }{0}).
+			End()
+
+		today := time.Now().UTC().Format("2006-01-02")
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?to="+url.QueryEscape(" "+today)).
+			Set("authorization", thirdUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(toExpected(publicResource)).
+			End()
+	})
+
+	t.Run("cannot filter resources with an invalid date", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/resource?from=yesterday").
+			Set("authorization", userToken).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"\"from\" must be a date in YYYY-MM-DD format"}`).
+			End()
+	})
+}
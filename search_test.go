@@ -0,0 +1,82 @@
+package main_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "WeKnow_api/libs/supertest"
+)
+
+func TestSearch(t *testing.T) {
+	initializeDatabase(t)
+	testServer := httptest.NewServer(app.Router)
+	defer closeDatabase(t)
+	defer testServer.Close()
+
+	type ExpectedResult struct {
+		Id    int64
+		Title string
+	}
+	type ExpectedResponse struct {
+		Resources []ExpectedResult
+	}
+
+	testUser := dummyData["testUser"].(map[string]interface{})
+	user, userToken := addTestUser(t, testUser)
+
+	anotherTestUser := dummyData["anotherTestUser"].(map[string]interface{})
+	_, anotherUserToken := addTestUser(t, anotherTestUser)
+
+	testResource := dummyData["testResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	publicResource := addTestResource(t, testResource)
+
+	testResource = dummyData["privateResource"].(map[string]interface{})
+	testResource["userId"] = user.Id
+	privateResource := addTestResource(t, testResource)
+
+	t.Run("cannot search without a query", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/search").
+			Set("authorization", userToken).
+			Expect(400).
+			Expect("Content-Type", "application/json").
+			Expect(`{"error":"A search query q is required"}`).
+			End()
+	})
+
+	t.Run("can search resources by tag", func(t *testing.T) {
+		expectedResponse := ExpectedResponse{[]ExpectedResult{
+			{privateResource.Id, privateResource.Title},
+		}}
+		Request(testServer.URL, t).
+			Get("/api/v1/search?q=golang&type=resources").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+	})
+
+	t.Run("only finds resources the user can access", func(t *testing.T) {
+		expectedResponse := ExpectedResponse{[]ExpectedResult{
+			{publicResource.Id, publicResource.Title},
+		}}
+		Request(testServer.URL, t).
+			Get("/api/v1/search?q=resource&type=resources").
+			Set("authorization", anotherUserToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			Expect(expectedResponse).
+			End()
+	})
+
+	t.Run("can search users by username", func(t *testing.T) {
+		Request(testServer.URL, t).
+			Get("/api/v1/search?q=anotherUser&type=users").
+			Set("authorization", userToken).
+			Expect(200).
+			Expect("Content-Type", "application/json").
+			End()
+	})
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"WeKnow_api/model"
+	"fmt"
+	"log"
+
+	"github.com/go-pg/pg/orm"
+)
+
+// AuditRecorder write audit events; events are only ever inserted, the
+// database rejects updates and deletes of them
+type AuditRecorder struct {
+	db orm.DB
+}
+
+// NewAuditRecorder create an audit recorder that writes to db
+func NewAuditRecorder(db orm.DB) *AuditRecorder {
+	return &AuditRecorder{db: db}
+}
+
+// Record insert an event; a failure is logged rather than returned so
+// auditing never fails the request it records
+func (ar *AuditRecorder) Record(event *model.AuditEvent) {
+	if err := ar.RecordTx(ar.db, event); err != nil {
+		log.Printf("Could not record %s audit event: %v", event.Action, err)
+	}
+}
+
+// RecordTx insert an event through tx, so it is only kept when the
+// transaction it describes commits
+func (ar *AuditRecorder) RecordTx(tx orm.DB, event *model.AuditEvent) error {
+	_, err := tx.Model(event).Insert()
+	return err
+}
+
+// Diff reduce the before and after values of a change to the fields
+// that changed; fields only in after count as changed
+func Diff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range after {
+		old, ok := before[key]
+		if ok && fmt.Sprint(old) == fmt.Sprint(value) {
+			continue
+		}
+		changedAfter[key] = value
+		if ok {
+			changedBefore[key] = old
+		}
+	}
+	return changedBefore, changedAfter
+}
@@ -0,0 +1,16 @@
+package services
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	before, after := Diff(
+		map[string]interface{}{"title": "Go", "privacy": "public", "views": int64(3)},
+		map[string]interface{}{"title": "Rust", "privacy": "public", "link": "https://x.y"},
+	)
+	if len(before) != 1 || before["title"] != "Go" {
+		t.Fatalf("Expected only the old title before; Got %v", before)
+	}
+	if len(after) != 2 || after["title"] != "Rust" || after["link"] != "https://x.y" {
+		t.Fatalf("Expected the new title and link after; Got %v", after)
+	}
+}
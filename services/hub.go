@@ -0,0 +1,111 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Event a real-time event delivered to a user
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Subscription a stream of events for a user
+type Subscription struct {
+	UserId int64
+	Events <-chan Event
+
+	events chan Event
+	closed bool
+}
+
+// Hub in-process pub/sub that fans out events to the subscriptions of a user
+type Hub struct {
+	// HeartbeatInterval how often idle streams are sent a heartbeat
+	HeartbeatInterval time.Duration
+	// IdleTimeout how long a stream stays open without any event
+	IdleTimeout time.Duration
+
+	bufferSize    int
+	mu            sync.Mutex
+	subscriptions map[int64]map[*Subscription]struct{}
+}
+
+// NewHub create a hub that buffers up to bufferSize events per subscription
+func NewHub(bufferSize int, heartbeatInterval, idleTimeout time.Duration) *Hub {
+	return &Hub{
+		HeartbeatInterval: heartbeatInterval,
+		IdleTimeout:       idleTimeout,
+		bufferSize:        bufferSize,
+		subscriptions:     map[int64]map[*Subscription]struct{}{},
+	}
+}
+
+// NewHubFromEnv create a hub configured by the STREAM_HEARTBEAT_INTERVAL
+// and STREAM_IDLE_TIMEOUT env vars
+func NewHubFromEnv() *Hub {
+	heartbeatInterval := durationFromEnv("STREAM_HEARTBEAT_INTERVAL", 25*time.Second)
+	idleTimeout := durationFromEnv("STREAM_IDLE_TIMEOUT", 10*time.Minute)
+	return NewHub(64, heartbeatInterval, idleTimeout)
+}
+
+// Subscribe open a subscription to the events of a user
+func (h *Hub) Subscribe(userId int64) *Subscription {
+	events := make(chan Event, h.bufferSize)
+	subscription := &Subscription{UserId: userId, Events: events, events: events}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscriptions[userId] == nil {
+		h.subscriptions[userId] = map[*Subscription]struct{}{}
+	}
+	h.subscriptions[userId][subscription] = struct{}{}
+	return subscription
+}
+
+// Unsubscribe close a subscription
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(subscription)
+}
+
+// Publish send an event to every subscription of a user without blocking;
+// subscriptions whose buffer is full are closed so slow clients reconnect
+// instead of holding back everyone else
+func (h *Hub) Publish(userId int64, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscription := range h.subscriptions[userId] {
+		select {
+		case subscription.events <- event:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+// Close close all subscriptions
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subscriptions := range h.subscriptions {
+		for subscription := range subscriptions {
+			h.remove(subscription)
+		}
+	}
+}
+
+// remove delete a subscription and close its events; h.mu must be held
+func (h *Hub) remove(subscription *Subscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+	close(subscription.events)
+	delete(h.subscriptions[subscription.UserId], subscription)
+	if len(h.subscriptions[subscription.UserId]) == 0 {
+		delete(h.subscriptions, subscription.UserId)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub(1, time.Second, time.Minute)
+
+	first := hub.Subscribe(1)
+	second := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+
+	t.Run("fans out events to every subscription of the user", func(t *testing.T) {
+		hub.Publish(1, Event{Type: "message", Data: "hello"})
+		for _, subscription := range []*Subscription{first, second} {
+			event := <-subscription.Events
+			if event.Type != "message" || event.Data != "hello" {
+				t.Fatalf("Expected message event; Got %v", event)
+			}
+		}
+		select {
+		case event := <-other.Events:
+			t.Fatalf("Expected no event for another user; Got %v", event)
+		default:
+		}
+	})
+
+	t.Run("closes subscriptions that fall behind", func(t *testing.T) {
+		hub.Publish(2, Event{Type: "follower"})
+		hub.Publish(2, Event{Type: "follower"})
+		if event := <-other.Events; event.Type != "follower" {
+			t.Fatalf("Expected follower event; Got %v", event)
+		}
+		if _, ok := <-other.Events; ok {
+			t.Fatal("Expected slow subscription to be closed")
+		}
+	})
+
+	t.Run("closes subscriptions on unsubscribe", func(t *testing.T) {
+		hub.Unsubscribe(first)
+		hub.Unsubscribe(first)
+		if _, ok := <-first.Events; ok {
+			t.Fatal("Expected subscription to be closed")
+		}
+	})
+
+	t.Run("closes all subscriptions on close", func(t *testing.T) {
+		hub.Close()
+		if _, ok := <-second.Events; ok {
+			t.Fatal("Expected subscription to be closed")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Mail an email message
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// NewMailerFromEnv create the mailer chosen by the MAILER env var: smtp,
+// log or memory; it defaults to smtp when SMTP_HOST is set. The log and
+// memory mailers keep the links in the emails, so they are only used when
+// MAILER names them.
+func NewMailerFromEnv() (Mailer, error) {
+	kind := os.Getenv("MAILER")
+	if kind == "" && os.Getenv("SMTP_HOST") != "" {
+		kind = "smtp"
+	}
+	switch kind {
+	case "smtp":
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		), nil
+	case "log":
+		return LogMailer{}, nil
+	case "memory":
+		return &MemoryMailer{}, nil
+	case "":
+		return nil, errors.New("Set SMTP_HOST, or MAILER to log or memory, to send emails")
+	default:
+		return nil, fmt.Errorf("MAILER must be one of smtp, log, memory; got %q", kind)
+	}
+}
+
+// SMTPMailer send emails through an SMTP server
+type SMTPMailer struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+// NewSMTPMailer create a mailer for the SMTP server at host:port; it
+// authenticates only when a username is given
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	if port == "" {
+		port = "587"
+	}
+	mailer := &SMTPMailer{address: host + ":" + port, from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer
+}
+
+// Send send an email
+func (m *SMTPMailer) Send(mail Mail) error {
+	// strip line breaks so header values cannot inject extra headers
+	header := strings.NewReplacer("\r", "", "\n", "")
+	message := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\n"+
+			"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		header.Replace(m.from), header.Replace(mail.To),
+		header.Replace(mail.Subject), mail.Body,
+	)
+	return smtp.SendMail(m.address, m.auth, m.from, []string{mail.To}, []byte(message))
+}
+
+// LogMailer write emails to the log instead of sending them
+type LogMailer struct{}
+
+// Send log an email
+func (LogMailer) Send(mail Mail) error {
+	log.Printf("Mail to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// MemoryMailer keep emails in memory so tests can read them
+type MemoryMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+// Send store an email
+func (m *MemoryMailer) Send(mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+// Mails get the emails sent to an address, oldest first
+func (m *MemoryMailer) Mails(to string) []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var mails []Mail
+	for _, mail := range m.mails {
+		if mail.To == to {
+			mails = append(mails, mail)
+		}
+	}
+	return mails
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNewMailerFromEnv(t *testing.T) {
+	defer os.Unsetenv("MAILER")
+	defer os.Unsetenv("SMTP_HOST")
+
+	os.Unsetenv("MAILER")
+	os.Unsetenv("SMTP_HOST")
+	if _, err := NewMailerFromEnv(); err == nil {
+		t.Fatal("Expected an error without SMTP_HOST or MAILER")
+	}
+
+	os.Setenv("MAILER", "log")
+	if mailer, _ := NewMailerFromEnv(); mailer != (LogMailer{}) {
+		t.Fatal("Expected log mailer when MAILER is log")
+	}
+
+	os.Setenv("MAILER", "stdout")
+	if _, err := NewMailerFromEnv(); err == nil {
+		t.Fatal("Expected an error with an unknown MAILER")
+	}
+
+	os.Unsetenv("MAILER")
+	os.Setenv("SMTP_HOST", "localhost")
+	if mailer, _ := NewMailerFromEnv(); mailer == nil {
+		t.Fatal("Expected SMTP mailer with SMTP_HOST")
+	} else if _, ok := mailer.(*SMTPMailer); !ok {
+		t.Fatal("Expected SMTP mailer with SMTP_HOST")
+	}
+
+	os.Setenv("MAILER", "memory")
+	if mailer, _ := NewMailerFromEnv(); mailer == nil {
+		t.Fatal("Expected memory mailer when MAILER is memory")
+	} else if _, ok := mailer.(*MemoryMailer); !ok {
+		t.Fatal("Expected memory mailer when MAILER is memory")
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &MemoryMailer{}
+	mailer.Send(Mail{To: "a@example.com", Subject: "first"})
+	mailer.Send(Mail{To: "b@example.com", Subject: "other"})
+	mailer.Send(Mail{To: "a@example.com", Subject: "second"})
+
+	mails := mailer.Mails("a@example.com")
+	if len(mails) != 2 || mails[0].Subject != "first" || mails[1].Subject != "second" {
+		t.Fatalf("Expected the two mails to a@example.com in order; Got %v", mails)
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+type viewKey struct {
+	ResourceId int64
+	UserId     int64
+}
+
+// ViewCounter count resource views once per user per window and
+// write the buffered counts to the database in batches
+type ViewCounter struct {
+	db       *pg.DB
+	window   time.Duration
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	seen    map[viewKey]time.Time
+	pending map[int64]int64
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewViewCounter create a view counter that deduplicates views within
+// window and flushes buffered counts every interval
+func NewViewCounter(db *pg.DB, window, interval time.Duration) *ViewCounter {
+	return &ViewCounter{
+		db:       db,
+		window:   window,
+		interval: interval,
+		now:      time.Now,
+		seen:     map[viewKey]time.Time{},
+		pending:  map[int64]int64{},
+	}
+}
+
+// NewViewCounterFromEnv create a view counter configured by the
+// VIEW_WINDOW and VIEW_FLUSH_INTERVAL env vars
+func NewViewCounterFromEnv(db *pg.DB) *ViewCounter {
+	window := durationFromEnv("VIEW_WINDOW", 30*time.Minute)
+	interval := durationFromEnv("VIEW_FLUSH_INTERVAL", 10*time.Second)
+	return NewViewCounter(db, window, interval)
+}
+
+// Record record a view of a resource by a user; it returns false if the
+// user already viewed the resource within the window
+func (vc *ViewCounter) Record(resourceId, userId int64) bool {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	key, now := viewKey{resourceId, userId}, vc.now()
+	if viewedAt, ok := vc.seen[key]; ok && now.Sub(viewedAt) < vc.window {
+		return false
+	}
+	vc.seen[key] = now
+	vc.pending[resourceId]++
+	return true
+}
+
+// Start flush buffered views periodically until Stop is called
+func (vc *ViewCounter) Start() {
+	vc.stop = make(chan struct{})
+	vc.stopped = make(chan struct{})
+	go func() {
+		defer close(vc.stopped)
+		ticker := time.NewTicker(vc.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := vc.Flush(); err != nil {
+					log.Printf("Could not flush resource views: %v", err)
+				}
+			case <-vc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stop the periodic flush and write any buffered views
+func (vc *ViewCounter) Stop() error {
+	if vc.stop != nil {
+		close(vc.stop)
+		<-vc.stopped
+		vc.stop = nil
+	}
+	return vc.Flush()
+}
+
+// Flush write buffered view counts to the database in a single update
+func (vc *ViewCounter) Flush() error {
+	vc.mu.Lock()
+	pending := vc.pending
+	vc.pending = map[int64]int64{}
+	now := vc.now()
+	for key, viewedAt := range vc.seen {
+		if now.Sub(viewedAt) >= vc.window {
+			delete(vc.seen, key)
+		}
+	}
+	vc.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// update rows in id order so concurrent flushes cannot deadlock
+	resourceIds := make([]int64, 0, len(pending))
+	for resourceId := range pending {
+		resourceIds = append(resourceIds, resourceId)
+	}
+	sort.Slice(resourceIds, func(i, j int) bool {
+		return resourceIds[i] < resourceIds[j]
+	})
+	rows := make([]string, 0, len(resourceIds))
+	values := make([]interface{}, 0, 2*len(resourceIds))
+	for _, resourceId := range resourceIds {
+		rows = append(rows, "(?::bigint, ?::bigint)")
+		values = append(values, resourceId, pending[resourceId])
+	}
+	query := fmt.Sprintf(
+		`UPDATE resources SET views = COALESCE(resources.views, 0) + v.count
+		FROM (VALUES %s) AS v(id, count) WHERE resources.id = v.id`,
+		strings.Join(rows, ", "),
+	)
+	if _, err := vc.db.Exec(query, values...); err != nil {
+		vc.mu.Lock()
+		for resourceId, count := range pending {
+			vc.pending[resourceId] += count
+		}
+		vc.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// durationFromEnv read a positive duration from an env var or use fallback
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestViewCounterRecord(t *testing.T) {
+	now := time.Now()
+	vc := NewViewCounter(nil, time.Hour, time.Minute)
+	vc.now = func() time.Time { return now }
+
+	t.Run("counts the first view of a user", func(t *testing.T) {
+		if !vc.Record(1, 10) {
+			t.Fatal("Expected first view to be counted")
+		}
+		if vc.pending[1] != 1 {
+			t.Fatalf("Expected 1 pending view; Got %v", vc.pending[1])
+		}
+	})
+
+	t.Run("ignores repeated views within the window", func(t *testing.T) {
+		now = now.Add(30 * time.Minute)
+		if vc.Record(1, 10) {
+			t.Fatal("Expected repeated view to be ignored")
+		}
+		if vc.pending[1] != 1 {
+			t.Fatalf("Expected 1 pending view; Got %v", vc.pending[1])
+		}
+	})
+
+	t.Run("counts views of other users", func(t *testing.T) {
+		if !vc.Record(1, 11) {
+			t.Fatal("Expected view by another user to be counted")
+		}
+		if vc.pending[1] != 2 {
+			t.Fatalf("Expected 2 pending views; Got %v", vc.pending[1])
+		}
+	})
+
+	t.Run("counts views again after the window", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		if !vc.Record(1, 10) {
+			t.Fatal("Expected view after the window to be counted")
+		}
+		if vc.pending[1] != 3 {
+			t.Fatalf("Expected 3 pending views; Got %v", vc.pending[1])
+		}
+	})
+}
+
+func TestViewCounterFlushWithoutViews(t *testing.T) {
+	vc := NewViewCounter(nil, time.Hour, time.Minute)
+	if err := vc.Flush(); err != nil {
+		t.Fatalf("Expected no error; Got %v", err)
+	}
+}
+
+func TestDurationFromEnv(t *testing.T) {
+	defer os.Unsetenv("VIEW_FLUSH_INTERVAL")
+	for value, expected := range map[string]time.Duration{
+		"5s":      5 * time.Second,
+		"0s":      time.Minute,
+		"-1s":     time.Minute,
+		"invalid": time.Minute,
+	} {
+		os.Setenv("VIEW_FLUSH_INTERVAL", value)
+		if duration := durationFromEnv("VIEW_FLUSH_INTERVAL", time.Minute); duration != expected {
+			t.Fatalf("Expected %v for %q; Got %v", expected, value, duration)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const EXP_EMAIL = "^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$"
@@ -49,6 +51,14 @@ func ValidateSignInRequest(user *User) error {
 	return err
 }
 
+// ValidateEmail validate an email address
+func ValidateEmail(email string) error {
+	if !re.MatchString(email) {
+		return errors.New("Please enter a valid email")
+	}
+	return nil
+}
+
 // ValidateNewCollection validate inputs submitted to create new collection
 func ValidateNewCollection(coll *Collection) error {
 
@@ -56,10 +66,34 @@ func ValidateNewCollection(coll *Collection) error {
 	var err error
 	if coll.Name == "" {
 		err = errors.New("Collection name is required")
+	} else if coll.Privacy == "" {
+		coll.Privacy = PrivateCollection
+	} else {
+		err = ValidateCollectionPrivacy(coll.Privacy)
 	}
 	return err
 }
 
+// ValidateSection trim the title of a section of a learning path and
+// validate its length
+func ValidateSection(section *string) error {
+	*section = strings.TrimSpace(*section)
+	if len([]rune(*section)) > 100 {
+		return errors.New("section must be at most 100 characters")
+	}
+	return nil
+}
+
+// ValidateCollectionPrivacy validate the privacy of a collection
+func ValidateCollectionPrivacy(privacy string) error {
+	if !Contains(CollectionPrivacies, privacy) {
+		return fmt.Errorf(
+			"privacy must be one of %s", strings.Join(CollectionPrivacies, ", "),
+		)
+	}
+	return nil
+}
+
 func ValidateProfileFields(user map[string]interface{}) error {
 
 	var err error
@@ -80,6 +114,10 @@ func ValidateProfileFields(user map[string]interface{}) error {
 			} else if len(value.(string)) < 11 || len(value.(string)) > 11 {
 				err = errors.New("Enter a valid phone number")
 			}
+		case "private":
+			if _, ok := value.(bool); !ok {
+				err = errors.New("Private must be true or false")
+			}
 		}
 	}
 	return err
@@ -91,6 +129,7 @@ func ValidateNewResource(resource *Resource) error {
 	resource.Type = strings.TrimSpace(resource.Type)
 	resource.Link = strings.TrimSpace(resource.Link)
 	resource.Privacy = strings.TrimSpace(resource.Privacy)
+	resource.Description = strings.TrimSpace(resource.Description)
 
 	var message string
 	var err error
@@ -171,6 +210,10 @@ func ValidateUpdateResourcePayload(payload map[string]interface{}) error {
 			if privacy, ok := value.(string); !ok || privacy == "" {
 				err = errors.New("A valid privacy is required")
 			}
+		case "description":
+			if _, ok := value.(string); !ok {
+				err = errors.New("description must be a string")
+			}
 		case "collectionId":
 			if collectionId, ok := value.(int64); !ok || collectionId == 0 {
 				err = errors.New("A valid collection Id is required")
@@ -230,3 +273,178 @@ func ValidateQueryParams(queryValues url.Values) error {
 	}
 	return nil
 }
+
+// ParseDate parse a date in YYYY-MM-DD or RFC3339 format
+func ParseDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ResourceFilters the filters and sorting of a list of resources, parsed
+// from query parameters
+type ResourceFilters struct {
+	Type     string
+	Tag      string
+	AuthorId int64
+	// resources created from From and until To match
+	From *time.Time
+	To   *time.Time
+	// ToDate is set when To is a bare date, to match the whole day
+	ToDate bool
+	Sort   string
+	Order  string
+}
+
+// ValidateResourceFilters validate and parse query parameters for listing
+// resources
+func ValidateResourceFilters(queryValues url.Values) (ResourceFilters, error) {
+	filters := ResourceFilters{Order: "desc"}
+	var err error
+	for key := range queryValues {
+		value := strings.TrimSpace(queryValues.Get(key))
+		switch key {
+		case "type":
+			if value != "audio" && value != "video" && value != "textual" {
+				err = errors.New(
+					"resource type must be one of 'video', 'audio' or 'textual'",
+				)
+			}
+			filters.Type = value
+		case "author":
+			if id, _ := strconv.ParseInt(value, 10, 64); id <= 0 {
+				err = errors.New("author must be a valid user Id")
+			} else {
+				filters.AuthorId = id
+			}
+		case "from", "to":
+			date, dateErr := ParseDate(value)
+			if dateErr != nil {
+				err = fmt.Errorf("%q must be a date in YYYY-MM-DD format", key)
+			} else if key == "from" {
+				filters.From = &date
+			} else {
+				filters.To = &date
+				_, dateErr = time.Parse("2006-01-02", value)
+				filters.ToDate = dateErr == nil
+			}
+		case "sort":
+			if value != "views" && value != "recommendations" &&
+				value != "rating" && value != "createdAt" {
+				err = errors.New(
+					"sort must be one of 'views', 'recommendations', 'rating' or 'createdAt'",
+				)
+			}
+			filters.Sort = value
+		case "order":
+			if value = strings.ToLower(value); value != "asc" && value != "desc" {
+				err = errors.New("order must be one of 'asc' or 'desc'")
+			}
+			filters.Order = value
+		case "tag":
+			if value == "" {
+				err = errors.New("tag cannot be empty")
+			}
+			filters.Tag = value
+		}
+	}
+	return filters, err
+}
+
+// ValidateSearchQuery validate query parameters for search
+func ValidateSearchQuery(queryValues url.Values) error {
+	var err error
+	switch searchType := queryValues.Get("type"); {
+	case strings.TrimSpace(queryValues.Get("q")) == "":
+		err = errors.New("A search query q is required")
+	case searchType != "" &&
+		searchType != "resources" &&
+		searchType != "tags" &&
+		searchType != "collections" &&
+		searchType != "users":
+		err = errors.New(
+			"type must be one of 'resources', 'tags', 'collections' or 'users'",
+		)
+	}
+	return err
+}
+
+// ValidateNewMessage validate the fields of a new message
+func ValidateNewMessage(message *Message) error {
+	message.Content = strings.TrimSpace(message.Content)
+	var err error
+	switch {
+	case message.Content == "":
+		err = errors.New("message Content is required, it cannot be empty")
+	case message.RecipientId == 0:
+		err = errors.New("recipientId is required, message must have a valid recipient")
+	case message.RecipientId == message.SenderId:
+		err = errors.New("You cannot message yourself")
+	}
+	return err
+}
+
+// ValidateNotificationSettings validate notification settings payload
+func ValidateNotificationSettings(payload map[string]interface{}) error {
+	if len(payload) == 0 {
+		return errors.New("No notification settings in request payload")
+	}
+	for key, value := range payload {
+		isType := false
+		for _, notificationType := range NotificationTypes {
+			isType = isType || key == notificationType
+		}
+		if !isType {
+			return fmt.Errorf("%q is not a notification type", key)
+		}
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%q must be true or false", key)
+		}
+	}
+	return nil
+}
+
+// ValidateNewReport validate the fields of a new report
+func ValidateNewReport(report *Report) error {
+	report.Details = strings.TrimSpace(report.Details)
+	var err error
+	switch {
+	case !Contains(ReportTargets, report.TargetType):
+		err = fmt.Errorf(
+			"targetType must be one of %s", strings.Join(ReportTargets, ", "),
+		)
+	case report.TargetId == 0:
+		err = errors.New("targetId is required")
+	case !Contains(ReportReasons, report.Reason):
+		err = fmt.Errorf(
+			"reason must be one of %s", strings.Join(ReportReasons, ", "),
+		)
+	case len(report.Details) > 1000:
+		err = errors.New("details cannot be longer than 1000 characters")
+	}
+	return err
+}
+
+// ValidateRating validate the stars and review of a rating
+func ValidateRating(rating *Rating) error {
+	rating.Review = strings.TrimSpace(rating.Review)
+	var err error
+	switch {
+	case rating.Stars < 1 || rating.Stars > 5:
+		err = errors.New("stars must be a whole number from 1 to 5")
+	case len(rating.Review) > 5000:
+		err = errors.New("review cannot be longer than 5000 characters")
+	}
+	return err
+}
+
+// Contains check if values contains value
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
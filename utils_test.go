@@ -3,10 +3,12 @@ package main_test
 import (
 	main "WeKnow_api"
 	. "WeKnow_api/model"
+	"WeKnow_api/services"
 	"WeKnow_api/utilities"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 var app main.App
@@ -80,6 +82,8 @@ func setUpApplication() {
 		"Password": os.Getenv("TEST_DB_PASSWORD"),
 		"Database": os.Getenv("TEST_DATABASE"),
 	}
+	// keep emails in memory so tests can read them
+	os.Setenv("MAILER", "memory")
 	app = main.CreateApp(dbConfig)
 }
 
@@ -101,11 +105,13 @@ func initializeDatabase(t *testing.T) {
 }
 
 func addTestUser(t *testing.T, testData map[string]interface{}) (User, string) {
+	now := time.Now()
 	user := User{
 		Username:    testData["username"].(string),
 		Email:       testData["email"].(string),
 		PhoneNumber: testData["phoneNumber"].(string),
 		Password:    testData["password"].(string),
+		VerifiedAt:  &now,
 	}
 
 	err := app.Db.Insert(&user)
@@ -211,3 +217,42 @@ func customizeEnvVariables(t *testing.T, variables map[string]string) {
 		}
 	}
 }
+
+func addTestRefreshToken(t *testing.T, userId int64, expiresAt time.Time) string {
+	token, err := utilities.GenerateRandomToken()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	refreshToken := RefreshToken{
+		UserId:    userId,
+		TokenHash: utilities.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	if err := app.Db.Insert(&refreshToken); err != nil {
+		t.Fatal(err.Error())
+	}
+	return token
+}
+
+// waitForMail wait for the mails sent in the background to an address
+func waitForMail(t *testing.T, to string, count int) []services.Mail {
+	mailer := app.Mailer.(*services.MemoryMailer)
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if mails := mailer.Mails(to); len(mails) >= count {
+			return mails
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %v mail(s) to %v", count, to)
+	return nil
+}
+
+func setTestUserRole(t *testing.T, userId int64, role string) {
+	_, err := app.Db.Model(&User{}).
+		Set("role = ?", role).
+		Where("id = ?", userId).
+		Update()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}